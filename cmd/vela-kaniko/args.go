@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// parseKeyValues parses a list of KEY=VALUE pairs, such as build args or
+// custom labels, from a parameter. The parameter may be provided as:
+//
+//   - a JSON map, such as {"FOO": "bar"}
+//   - a JSON list, such as ["FOO=bar"] or [{"FOO": "bar"}]
+//   - a YAML list, such as [FOO=bar, BAZ=qux] or lines of - FOO=bar
+//   - comma separated values, such as FOO=bar,BAZ=qux
+//
+// Vela joins a YAML list of strings with commas, so in comma separated values
+// a value without a key, such as the b in FOO=a,b,BAR=c, is treated as part of
+// the previous value, and a quoted value, such as "FOO=a,b" or FOO="a,b", is
+// kept as-is. Each pair must contain a key, and the value may contain =.
+func parseKeyValues(value string) ([]string, error) {
+	trimmed := strings.TrimSpace(value)
+
+	var (
+		pairs []string
+		err   error
+	)
+
+	switch {
+	case len(trimmed) == 0:
+		return nil, nil
+	case strings.HasPrefix(trimmed, "{"):
+		pairs, err = parseJSONMap([]byte(trimmed))
+	case strings.HasPrefix(trimmed, "["):
+		// check if the list is JSON, otherwise it is a YAML flow sequence
+		switch {
+		case json.Valid([]byte(trimmed)):
+			pairs, err = parseJSONList([]byte(trimmed))
+		case strings.HasSuffix(trimmed, "]"):
+			pairs, err = splitValues(trimmed[1 : len(trimmed)-1])
+		default:
+			err = fmt.Errorf("invalid list %s: missing closing ]", trimmed)
+		}
+	case strings.HasPrefix(trimmed, "-"):
+		pairs, err = parseYAMLList(trimmed)
+	default:
+		pairs, err = splitValues(trimmed)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// verify each pair is in the format KEY=VALUE
+	for _, pair := range pairs {
+		key, _, ok := strings.Cut(pair, "=")
+
+		if !ok || len(key) == 0 || strings.ContainsFunc(key, unicode.IsSpace) {
+			return nil, fmt.Errorf("%q is not in the format KEY=VALUE", pair)
+		}
+	}
+
+	return pairs, nil
+}
+
+// sortPairs returns a copy of the KEY=VALUE pairs sorted by key. Pairs with
+// the same key keep their order, so the later pair still takes precedence.
+func sortPairs(pairs []string) []string {
+	sorted := slices.Clone(pairs)
+
+	slices.SortStableFunc(sorted, func(a, b string) int {
+		keyA, _, _ := strings.Cut(a, "=")
+		keyB, _, _ := strings.Cut(b, "=")
+
+		return strings.Compare(keyA, keyB)
+	})
+
+	return sorted
+}
+
+// parseJSONMap parses the KEY=VALUE pairs from a JSON map, sorted by key.
+func parseJSONMap(data []byte) ([]string, error) {
+	values := make(map[string]any)
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON map: %w", err)
+	}
+
+	pairs := []string{}
+
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		switch v := values[key].(type) {
+		case string, json.Number, bool:
+			pairs = append(pairs, fmt.Sprintf("%s=%v", key, v))
+		default:
+			return nil, fmt.Errorf("invalid JSON map: value for %s must be a string, number or boolean", key)
+		}
+	}
+
+	return pairs, nil
+}
+
+// parseJSONList parses the KEY=VALUE pairs from a JSON list of strings or maps.
+func parseJSONList(data []byte) ([]string, error) {
+	list := []json.RawMessage{}
+
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON list: %w", err)
+	}
+
+	pairs := []string{}
+
+	for _, item := range list {
+		// check if the item is a map, such as from a YAML list of maps
+		if bytes.HasPrefix(bytes.TrimSpace(item), []byte("{")) {
+			m, err := parseJSONMap(item)
+			if err != nil {
+				return nil, err
+			}
+
+			pairs = append(pairs, m...)
+
+			continue
+		}
+
+		var pair string
+
+		err = json.Unmarshal(item, &pair)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON list: item %s must be a string or map", item)
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, nil
+}
+
+// parseYAMLList parses the KEY=VALUE pairs from a YAML block sequence, such as:
+//
+//   - FOO=bar
+//   - "BAZ=a,b"
+func parseYAMLList(value string) ([]string, error) {
+	pairs := []string{}
+
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+
+		// skip empty lines and comments
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		item, ok := strings.CutPrefix(line, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid YAML list: line %q is not a list item", line)
+		}
+
+		pairs = append(pairs, unquote(strings.TrimSpace(item)))
+	}
+
+	return pairs, nil
+}
+
+// splitValues splits comma separated KEY=VALUE pairs, ignoring commas within
+// quotes and treating a value without a key as part of the previous value.
+func splitValues(value string) ([]string, error) {
+	fields := []string{}
+
+	var (
+		field strings.Builder
+		quote rune
+	)
+
+	for _, c := range value {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\'') && opensQuote(field.String()):
+			quote = c
+		case quote == 0 && c == ',':
+			fields = append(fields, field.String())
+			field.Reset()
+
+			continue
+		}
+
+		field.WriteRune(c)
+	}
+
+	// verify all quotes are closed
+	if quote != 0 {
+		return nil, fmt.Errorf("invalid value %s: missing closing %c", value, quote)
+	}
+
+	fields = append(fields, field.String())
+
+	pairs := []string{}
+
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+
+		// skip empty fields, such as from a trailing comma
+		if len(f) == 0 {
+			continue
+		}
+
+		pair := unquote(f)
+
+		// check if the field is part of the previous value
+		key, _, ok := strings.Cut(pair, "=")
+		if (!ok || strings.ContainsFunc(key, unicode.IsSpace)) && len(pairs) > 0 && pair == f {
+			pairs[len(pairs)-1] += "," + f
+
+			continue
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, nil
+}
+
+// opensQuote checks if a quote following the field opens a quoted pair or
+// value, so that a quote within a value, such as it's, is kept as-is.
+func opensQuote(field string) bool {
+	field = strings.TrimSpace(field)
+
+	return len(field) == 0 || (strings.HasSuffix(field, "=") && strings.Count(field, "=") == 1)
+}
+
+// unquote removes the quotes surrounding a KEY=VALUE pair or its value.
+func unquote(pair string) string {
+	// check if the whole pair is quoted, such as "FOO=a,b"
+	if len(pair) >= 2 && (pair[0] == '"' || pair[0] == '\'') && pair[len(pair)-1] == pair[0] {
+		return pair[1 : len(pair)-1]
+	}
+
+	// check if the value is quoted, such as FOO="a,b"
+	key, value, ok := strings.Cut(pair, "=")
+	if ok && len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return key + "=" + value[1:len(value)-1]
+	}
+
+	return pair
+}
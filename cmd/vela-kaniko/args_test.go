@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDocker_parseKeyValues(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  nil,
+		},
+		{
+			name:  "JSON map",
+			value: `{"VERSION": "1.2.3", "CHECKSUM": "a,b=c", "DEBUG": true, "PORT": 8080}`,
+			want:  []string{"CHECKSUM=a,b=c", "DEBUG=true", "PORT=8080", "VERSION=1.2.3"},
+		},
+		{
+			name:  "JSON list",
+			value: `["VERSION=1.2.3", "CHECKSUM=a,b=c"]`,
+			want:  []string{"VERSION=1.2.3", "CHECKSUM=a,b=c"},
+		},
+		{
+			name:  "JSON list of maps",
+			value: `[{"VERSION": "1.2.3"}, {"CHECKSUM": "a,b=c"}]`,
+			want:  []string{"VERSION=1.2.3", "CHECKSUM=a,b=c"},
+		},
+		{
+			name:  "YAML flow list",
+			value: `[VERSION=1.2.3, "CHECKSUM=a,b=c"]`,
+			want:  []string{"VERSION=1.2.3", "CHECKSUM=a,b=c"},
+		},
+		{
+			name:  "YAML block list",
+			value: "- VERSION=1.2.3\n- \"CHECKSUM=a,b=c\"\n",
+			want:  []string{"VERSION=1.2.3", "CHECKSUM=a,b=c"},
+		},
+		{
+			name:  "comma separated values",
+			value: "VERSION=1.2.3,FOO=bar",
+			want:  []string{"VERSION=1.2.3", "FOO=bar"},
+		},
+		{
+			name:  "quoted comma separated values",
+			value: `"HOSTS=a,b",FOO='c,d',MSG=it's`,
+			want:  []string{"HOSTS=a,b", "FOO=c,d", "MSG=it's"},
+		},
+		{
+			name:  "embedded equals and commas",
+			value: "QUERY=a=1&b=2,HOSTS=a,b,c,FOO=bar",
+			want:  []string{"QUERY=a=1&b=2", "HOSTS=a,b,c", "FOO=bar"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseKeyValues(test.value)
+			if err != nil {
+				t.Errorf("parseKeyValues returned err: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseKeyValues is %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_parseKeyValues_Invalid(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{
+			name:  "invalid JSON map",
+			value: `{"VERSION": "1.2.3"`,
+		},
+		{
+			name:  "nested JSON map",
+			value: `{"VERSION": {"major": 1}}`,
+		},
+		{
+			name:  "JSON list of numbers",
+			value: `[1, 2]`,
+		},
+		{
+			name:  "unclosed list",
+			value: `[VERSION=1.2.3`,
+		},
+		{
+			name:  "unclosed quote",
+			value: `FOO="bar`,
+		},
+		{
+			name:  "missing key",
+			value: "=bar",
+		},
+		{
+			name:  "missing value",
+			value: "FOO",
+		},
+		{
+			name:  "invalid YAML list",
+			value: "- FOO=bar\nBAZ=qux",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseKeyValues(test.value)
+			if err == nil {
+				t.Errorf("parseKeyValues should have returned err")
+			}
+		})
+	}
+}
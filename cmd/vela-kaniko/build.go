@@ -11,8 +11,22 @@ import (
 
 // Build represents the plugin configuration for build information.
 type Build struct {
+	// branch for the build
+	Branch string
+	// default branch for the repository
+	DefaultBranch string
 	// event generated for build
 	Event string
+	// number of the pull request for the build
+	PullRequest string
+	// source branch of the pull request for the build
+	PullRequestSource string
+	// target branch of the pull request for the build
+	PullRequestTarget string
+	// target environment of the deployment for the build
+	Deployment string
+	// name of the schedule for the build
+	Schedule string
 	// SHA-1 hash generated for commit
 	Sha string
 	// control how to snapshot the filesystem. - options (full|redo|time)
@@ -31,6 +45,12 @@ type Build struct {
 	IgnorePath []string
 	// https://github.com/GoogleContainerTools/kaniko#flag---log-timestamp
 	LogTimestamp bool
+	// https://github.com/GoogleContainerTools/kaniko#flag---cleanup
+	Cleanup bool
+	// https://github.com/GoogleContainerTools/kaniko#flag---digest-file
+	DigestFile string
+	// https://github.com/GoogleContainerTools/kaniko#flag---reproducible
+	Reproducible bool
 }
 
 // SnapshotModeValues represents the available options for setting a snapshot mode.
@@ -63,6 +83,26 @@ func (b *Build) Validate() error {
 	return nil
 }
 
+// parseSchedule returns the name of the schedule from the message Vela
+// creates for a schedule event, such as "triggered for nightly schedule
+// with 0 0 * * * entry", which is the only place Vela provides the name.
+//
+// https://github.com/go-vela/server/blob/main/cmd/vela-server/schedule.go
+func parseSchedule(message string) string {
+	rest, ok := strings.CutPrefix(message, "triggered for ")
+	if !ok {
+		return ""
+	}
+
+	// the cron entry never contains the separator, so the last one is used
+	i := strings.LastIndex(rest, " schedule with ")
+	if i < 0 {
+		return ""
+	}
+
+	return rest[:i]
+}
+
 // isSnapshotModeValid checks if a value is within the list of accepted values.
 func isSnapshotModeValid(value string) bool {
 	// loop through snapshot values checking the value against the list
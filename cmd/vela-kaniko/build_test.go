@@ -59,3 +59,25 @@ func TestDocker_Build_Validate_InvalidSnapshotMode(t *testing.T) {
 		t.Errorf("Validate should have returned err")
 	}
 }
+
+func TestDocker_parseSchedule(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{message: "triggered for nightly schedule with 0 0 * * * entry", want: "nightly"},
+		{message: "triggered for weekly rebuild schedule with @weekly entry", want: "weekly rebuild"},
+		{message: "Merge pull request #42 from octocat/feature", want: ""},
+		{message: "", want: ""},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := parseSchedule(test.message)
+
+		if got != test.want {
+			t.Errorf("parseSchedule for %q is %q, want %q", test.message, got, test.want)
+		}
+	}
+}
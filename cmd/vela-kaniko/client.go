@@ -0,0 +1,595 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// clientTimeout defines the timeout for each request to a registry.
+const clientTimeout = 30 * time.Second
+
+// maxManifestSize defines the largest manifest read from a registry.
+//
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pushing-manifests
+const maxManifestSize = 4 << 20
+
+const (
+	// ociIndexMediaType defines the media type of an OCI image index.
+	ociIndexMediaType = "application/vnd.oci.image.index.v1+json"
+
+	// ociManifestMediaType defines the media type of an OCI image manifest.
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+
+	// dockerListMediaType defines the media type of a Docker manifest list.
+	dockerListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	// dockerManifestMediaType defines the media type of a Docker image manifest.
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// manifestMediaTypes defines the media types of the image manifests accepted from a registry.
+var manifestMediaTypes = []string{
+	ociIndexMediaType,
+	ociManifestMediaType,
+	dockerListMediaType,
+	dockerManifestMediaType,
+}
+
+var (
+	// errUnauthorized defines the error returned
+	// when authentication with a registry fails.
+	errUnauthorized = errors.New("registry authentication failed")
+
+	// errForbidden defines the error returned when the
+	// credentials do not allow pushing to a repository.
+	errForbidden = errors.New("registry push permission denied")
+)
+
+type (
+	// registryClient represents a minimal client for the Docker Registry HTTP API V2.
+	//
+	// https://distribution.github.io/distribution/spec/api/
+	registryClient struct {
+		// http client for communicating with the registry
+		client *http.Client
+		// scheme and host of the registry
+		base *url.URL
+		// authentication for the registry
+		auth dockerAuth
+		// use basic authentication for each request
+		basic bool
+		// bearer tokens for each requested scope
+		tokens map[string]string
+	}
+
+	// challenge represents a WWW-Authenticate challenge from a registry.
+	//
+	// https://distribution.github.io/distribution/spec/auth/token/
+	challenge struct {
+		// authentication scheme - options (basic|bearer)
+		Scheme string
+		// authentication parameters such as realm and service
+		Params map[string]string
+	}
+
+	// tagsResponse represents the response from listing the tags of a repository.
+	tagsResponse struct {
+		Tags []string `json:"tags"`
+	}
+
+	// tokenResponse represents the response from a registry token server.
+	tokenResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+)
+
+// newRegistryClient creates a registryClient for the host from the registry configuration.
+func newRegistryClient(r *Registry, host string) (*registryClient, error) {
+	logrus.Tracef("creating registry client for %s", host)
+
+	// capture the authentication for all registries
+	config, err := r.merged()
+	if err != nil {
+		return nil, err
+	}
+
+	host = registryHost(host)
+	scheme := "https"
+
+	// insecure registries are communicated with over plain HTTP
+	if r.InsecurePush || slices.ContainsFunc(r.InsecureRegistries, func(insecure string) bool {
+		return registryHost(insecure) == host
+	}) {
+		scheme = "http"
+	}
+
+	auth, _ := config.lookup(host)
+
+	return &registryClient{
+		client: &http.Client{Timeout: clientTimeout},
+		base:   &url.URL{Scheme: scheme, Host: host},
+		auth:   auth,
+		tokens: make(map[string]string),
+	}, nil
+}
+
+// ping verifies the client can authenticate with the registry for the scope.
+func (c *registryClient) ping(ctx context.Context, scope string) error {
+	logrus.Tracef("pinging registry %s", c.base.Host)
+
+	resp, err := c.do(ctx, http.MethodGet, "/v2/", nil, nil, scope)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w for %s: %s", errUnauthorized, c.base.Host, resp.Status)
+	default:
+		return fmt.Errorf("unexpected response from registry %s: %s", c.base.Host, resp.Status)
+	}
+}
+
+// probePush verifies the client can push to the repository by starting
+// a blob upload session and then cancelling it.
+//
+// https://distribution.github.io/distribution/spec/api/#starting-an-upload
+func (c *registryClient) probePush(ctx context.Context, repo string) error {
+	logrus.Tracef("probing push permission for %s/%s", c.base.Host, repo)
+
+	scope := fmt.Sprintf("repository:%s:pull,push", repo)
+
+	resp, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/v2/%s/blobs/uploads/", repo), nil, nil, scope)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w for %s/%s: %s", errForbidden, c.base.Host, repo, resp.Status)
+	default:
+		return fmt.Errorf("unexpected response starting upload to %s/%s: %s", c.base.Host, repo, resp.Status)
+	}
+
+	location := resp.Header.Get("Location")
+	if len(location) == 0 {
+		return nil
+	}
+
+	// cancel the upload session since nothing will be uploaded
+	cancel, err := c.do(ctx, http.MethodDelete, location, nil, nil, scope)
+	if err != nil {
+		logrus.Warnf("unable to cancel upload to %s/%s: %v", c.base.Host, repo, err)
+
+		return nil
+	}
+	defer cancel.Body.Close()
+
+	return nil
+}
+
+// tags lists the tags of the repository, following each page of results.
+// A repository that does not exist yet has no tags.
+//
+// https://distribution.github.io/distribution/spec/api/#listing-image-tags
+func (c *registryClient) tags(ctx context.Context, repo string) ([]string, error) {
+	logrus.Tracef("listing tags for %s/%s", c.base.Host, repo)
+
+	scope := fmt.Sprintf("repository:%s:pull", repo)
+	path := fmt.Sprintf("/v2/%s/tags/list", repo)
+
+	tags := []string{}
+
+	for len(path) > 0 {
+		page, next, err := c.tagsPage(ctx, path, repo, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, page...)
+		path = next
+	}
+
+	return tags, nil
+}
+
+// tagsPage requests a single page of tags for the repository and returns the path to the next page.
+func (c *registryClient) tagsPage(ctx context.Context, path, repo, scope string) ([]string, string, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, nil, nil, scope)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, "", fmt.Errorf("%w for %s/%s: %s", errUnauthorized, c.base.Host, repo, resp.Status)
+	default:
+		return nil, "", fmt.Errorf("unexpected response listing tags for %s/%s: %s", c.base.Host, repo, resp.Status)
+	}
+
+	t := new(tagsResponse)
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(t)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to parse tags for %s/%s: %w", c.base.Host, repo, err)
+	}
+
+	return t.Tags, nextLink(resp.Header.Get("Link")), nil
+}
+
+// manifestDigest returns the digest of the manifest for the tag in the
+// repository, or an empty digest if the manifest does not exist.
+//
+// https://distribution.github.io/distribution/spec/api/#existing-manifests
+func (c *registryClient) manifestDigest(ctx context.Context, repo, tag string) (string, error) {
+	logrus.Tracef("checking for manifest %s/%s:%s", c.base.Host, repo, tag)
+
+	header := http.Header{}
+	header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+
+	resp, err := c.do(ctx, http.MethodHead, fmt.Sprintf("/v2/%s/manifests/%s", repo, tag), header, nil, fmt.Sprintf("repository:%s:pull", repo))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", fmt.Errorf("%w for %s/%s: %s", errUnauthorized, c.base.Host, repo, resp.Status)
+	default:
+		return "", fmt.Errorf("unexpected response checking for %s/%s:%s: %s", c.base.Host, repo, tag, resp.Status)
+	}
+
+	// check if the registry returned the digest
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if len(digest) > 0 {
+		return digest, nil
+	}
+
+	// compute the digest from the manifest otherwise
+	d, err := c.manifest(ctx, repo, tag)
+	if err != nil {
+		return "", err
+	}
+
+	return d.Digest, nil
+}
+
+// manifest fetches the manifest for the reference in the repository and returns a descriptor for it.
+//
+// https://distribution.github.io/distribution/spec/api/#pulling-an-image-manifest
+func (c *registryClient) manifest(ctx context.Context, repo, ref string) (*descriptor, error) {
+	logrus.Tracef("fetching manifest %s/%s:%s", c.base.Host, repo, ref)
+
+	header := http.Header{}
+	header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+
+	resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v2/%s/manifests/%s", repo, ref), header, nil, fmt.Sprintf("repository:%s:pull", repo))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("%w for %s/%s: %s", errUnauthorized, c.base.Host, repo, resp.Status)
+	default:
+		return nil, fmt.Errorf("unexpected response fetching %s/%s:%s: %s", c.base.Host, repo, ref, resp.Status)
+	}
+
+	// read past the limit to detect a manifest that is too large,
+	// since a truncated manifest would produce the wrong digest
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest %s/%s:%s: %w", c.base.Host, repo, ref, err)
+	}
+
+	if len(body) > maxManifestSize {
+		return nil, fmt.Errorf("manifest %s/%s:%s exceeds %d bytes", c.base.Host, repo, ref, maxManifestSize)
+	}
+
+	// the digest is computed from the content, rather than
+	// trusting the Docker-Content-Digest header if returned
+	sum := sha256.Sum256(body)
+
+	mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+
+	return &descriptor{
+		MediaType: strings.TrimSpace(mediaType),
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Size:      int64(len(body)),
+	}, nil
+}
+
+// putManifest publishes the manifest under the reference in the repository.
+//
+// https://distribution.github.io/distribution/spec/api/#pushing-an-image-manifest
+func (c *registryClient) putManifest(ctx context.Context, repo, ref, mediaType string, body []byte) error {
+	logrus.Tracef("publishing manifest %s/%s:%s", c.base.Host, repo, ref)
+
+	header := http.Header{}
+	header.Set("Content-Type", mediaType)
+
+	resp, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/v2/%s/manifests/%s", repo, ref), header, body, fmt.Sprintf("repository:%s:pull,push", repo))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w for %s/%s: %s", errForbidden, c.base.Host, repo, resp.Status)
+	default:
+		return fmt.Errorf("unexpected response publishing %s/%s:%s: %s", c.base.Host, repo, ref, resp.Status)
+	}
+}
+
+// do sends a request to the registry, authenticating for the scope when challenged.
+func (c *registryClient) do(ctx context.Context, method, path string, header http.Header, body []byte, scope string) (*http.Response, error) {
+	resp, err := c.send(ctx, method, path, header, body, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if the registry challenged the request
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	ch := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+
+	resp.Body.Close()
+
+	// authenticate with the registry for the challenge
+	err = c.authorize(ctx, ch, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.send(ctx, method, path, header, body, scope)
+}
+
+// send sends a single request to the registry with any existing authentication.
+func (c *registryClient) send(ctx context.Context, method, path string, header http.Header, body []byte, scope string) (*http.Response, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve the path against the registry, which allows
+	// following locations returned from the registry
+	u := c.base.ResolveReference(ref)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	// add any existing authentication to the request
+	if token, ok := c.tokens[scope]; ok {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if c.basic {
+		username, password := c.auth.credentials()
+		req.SetBasicAuth(username, password)
+	}
+
+	logrus.Tracef("sending %s request to %s", method, u.Redacted())
+
+	return c.client.Do(req)
+}
+
+// authorize obtains the authentication for the challenge and scope.
+func (c *registryClient) authorize(ctx context.Context, ch *challenge, scope string) error {
+	username, password := c.auth.credentials()
+
+	switch ch.Scheme {
+	case "basic":
+		// verify credentials are available for basic authentication
+		if len(username) == 0 && len(password) == 0 {
+			return fmt.Errorf("%w for %s: no credentials provided", errUnauthorized, c.base.Host)
+		}
+
+		c.basic = true
+
+		return nil
+	case "bearer":
+		// check if a bearer token is provided for the registry
+		if len(c.auth.RegistryToken) > 0 {
+			c.tokens[scope] = c.auth.RegistryToken
+
+			return nil
+		}
+
+		token, err := c.token(ctx, ch, scope)
+		if err != nil {
+			return err
+		}
+
+		c.tokens[scope] = token
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported authentication scheme %q from registry %s", ch.Scheme, c.base.Host)
+	}
+}
+
+// token requests a bearer token for the scope from the token server in the challenge.
+//
+// https://distribution.github.io/distribution/spec/auth/token/
+// https://distribution.github.io/distribution/spec/auth/oauth/
+func (c *registryClient) token(ctx context.Context, ch *challenge, scope string) (string, error) {
+	realm, err := url.Parse(ch.Params["realm"])
+	if err != nil || len(realm.Host) == 0 {
+		return "", fmt.Errorf("invalid token realm %q from registry %s", ch.Params["realm"], c.base.Host)
+	}
+
+	logrus.Tracef("requesting token for scope %s from %s", scope, realm.Host)
+
+	var req *http.Request
+
+	// check if an identity token is provided for the registry
+	if len(c.auth.IdentityToken) > 0 {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", c.auth.IdentityToken)
+		form.Set("service", ch.Params["service"])
+		form.Set("scope", scope)
+		form.Set("client_id", "vela-kaniko")
+
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := realm.Query()
+		query.Set("service", ch.Params["service"])
+		query.Set("scope", scope)
+		realm.RawQuery = query.Encode()
+
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+
+		// add any credentials for the registry
+		username, password := c.auth.credentials()
+		if len(username) > 0 || len(password) > 0 {
+			req.SetBasicAuth(username, password)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", fmt.Errorf("%w for %s: token request returned %s", errUnauthorized, c.base.Host, resp.Status)
+	default:
+		return "", fmt.Errorf("unexpected response from token server %s: %s", realm.Host, resp.Status)
+	}
+
+	t := new(tokenResponse)
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(t)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse token response from %s: %w", realm.Host, err)
+	}
+
+	// the token server may use either field for the token
+	if len(t.Token) > 0 {
+		return t.Token, nil
+	}
+
+	if len(t.AccessToken) > 0 {
+		return t.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("no token returned from token server %s", realm.Host)
+}
+
+// parseChallenge parses the value of a WWW-Authenticate header.
+//
+// https://datatracker.ietf.org/doc/html/rfc7235#section-4.1
+func parseChallenge(header string) *challenge {
+	ch := &challenge{
+		Params: make(map[string]string),
+	}
+
+	scheme, params, _ := strings.Cut(strings.TrimSpace(header), " ")
+	ch.Scheme = strings.ToLower(scheme)
+
+	for len(params) > 0 {
+		var key, value string
+
+		params = strings.TrimLeft(params, " ,")
+
+		key, params, _ = strings.Cut(params, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		// check if the value is quoted, since quoted values may contain commas
+		if strings.HasPrefix(params, `"`) {
+			value, params, _ = strings.Cut(params[1:], `"`)
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+		}
+
+		if len(key) > 0 {
+			ch.Params[key] = value
+		}
+	}
+
+	return ch
+}
+
+// nextLink returns the target of the next page from the value of a Link header.
+//
+// https://datatracker.ietf.org/doc/html/rfc8288
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, _ := strings.Cut(link, ";")
+
+		// check if the link is for the next page
+		if strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+
+	return ""
+}
+
+// credentials returns the username and password for the dockerAuth.
+func (a *dockerAuth) credentials() (string, string) {
+	// check if a basic authentication value is provided
+	if len(a.Auth) > 0 {
+		decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+		if err == nil {
+			username, password, _ := strings.Cut(string(decoded), ":")
+
+			return username, password
+		}
+	}
+
+	return a.Username, a.Password
+}
@@ -0,0 +1,409 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestDocker_Registry_CheckAuth_Basic(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup registry
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "octocat" || password != "superSecretPassword" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	// setup tests
+	tests := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{
+			name:     "valid credentials",
+			password: "superSecretPassword",
+			want:     nil,
+		},
+		{
+			name:     "invalid credentials",
+			password: "wrongPassword",
+			want:     errUnauthorized,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Registry{
+				Name:               host,
+				Username:           "octocat",
+				Password:           test.password,
+				InsecureRegistries: []string{host},
+			}
+
+			err := r.CheckAuth(t.Context(), host+"/target/vela-kaniko")
+			if !errors.Is(err, test.want) {
+				t.Errorf("CheckAuth returned err: %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Registry_CheckAuth_Bearer(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup registry
+	s := httptest.NewServer(nil)
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer superSecretBearerToken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.example.com"`, s.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if r.Form.Get("scope") != "repository:target/vela-kaniko:pull,push" ||
+			r.Form.Get("service") != "registry.example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		username, password, _ := r.BasicAuth()
+
+		switch {
+		case username == "octocat" && password == "superSecretPassword":
+		case r.Form.Get("refresh_token") == "superSecretRefreshToken":
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		fmt.Fprint(w, `{"token": "superSecretBearerToken"}`)
+	})
+
+	s.Config.Handler = mux
+
+	// setup tests
+	tests := []struct {
+		name     string
+		registry *Registry
+		want     error
+	}{
+		{
+			name: "valid credentials",
+			registry: &Registry{
+				Username: "octocat",
+				Password: "superSecretPassword",
+			},
+			want: nil,
+		},
+		{
+			name: "identity token",
+			registry: &Registry{
+				IdentityToken: "superSecretRefreshToken",
+			},
+			want: nil,
+		},
+		{
+			name: "registry token",
+			registry: &Registry{
+				RegistryToken: "superSecretBearerToken",
+			},
+			want: nil,
+		},
+		{
+			name: "invalid credentials",
+			registry: &Registry{
+				Username: "octocat",
+				Password: "wrongPassword",
+			},
+			want: errUnauthorized,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.registry.Name = host
+			test.registry.InsecureRegistries = []string{host}
+
+			err := test.registry.CheckAuth(t.Context(), host+"/target/vela-kaniko")
+			if !errors.Is(err, test.want) {
+				t.Errorf("CheckAuth returned err: %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_parseChallenge(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		header string
+		want   *challenge
+	}{
+		{
+			header: `Basic realm="registry"`,
+			want: &challenge{
+				Scheme: "basic",
+				Params: map[string]string{"realm": "registry"},
+			},
+		},
+		{
+			header: `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:target/vela-kaniko:pull,push"`,
+			want: &challenge{
+				Scheme: "bearer",
+				Params: map[string]string{
+					"realm":   "https://auth.docker.io/token",
+					"service": "registry.docker.io",
+					"scope":   "repository:target/vela-kaniko:pull,push",
+				},
+			},
+		},
+		{
+			header: `Bearer realm=https://auth.example.com/token, service=registry`,
+			want: &challenge{
+				Scheme: "bearer",
+				Params: map[string]string{
+					"realm":   "https://auth.example.com/token",
+					"service": "registry",
+				},
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := parseChallenge(test.header)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseChallenge is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestDocker_Registry_CheckPush(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// track the cancelled upload sessions
+	cancelled := []string{}
+
+	// setup registry
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/target/vela-kaniko/blobs/uploads/":
+			w.Header().Set("Location", "/v2/target/vela-kaniko/blobs/uploads/1234?_state=abc")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodDelete:
+			cancelled = append(cancelled, r.URL.Path)
+
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	r := &Registry{
+		Name:               host,
+		Username:           "octocat",
+		Password:           "superSecretPassword",
+		InsecureRegistries: []string{host},
+	}
+
+	err := r.CheckPush(t.Context(), host+"/target/vela-kaniko")
+	if err != nil {
+		t.Errorf("CheckPush returned err: %v", err)
+	}
+
+	if len(cancelled) != 1 || cancelled[0] != "/v2/target/vela-kaniko/blobs/uploads/1234" {
+		t.Errorf("CheckPush cancelled %v, want upload 1234", cancelled)
+	}
+
+	err = r.CheckPush(t.Context(), host+"/target/read-only")
+	if !errors.Is(err, errForbidden) {
+		t.Errorf("CheckPush returned err: %v, want %v", err, errForbidden)
+	}
+}
+
+func TestDocker_Registry_ListTags(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup registry
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/target/vela-kaniko/tags/list" && len(r.URL.Query().Get("last")) == 0:
+			w.Header().Set("Link", `</v2/target/vela-kaniko/tags/list?last=v1.0.0&n=2>; rel="next"`)
+			_, _ = w.Write([]byte(`{"name": "target/vela-kaniko", "tags": ["latest", "v1.0.0"]}`))
+		case r.URL.Path == "/v2/target/vela-kaniko/tags/list":
+			_, _ = w.Write([]byte(`{"name": "target/vela-kaniko", "tags": ["v1.1.0"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	r := &Registry{
+		Name:               host,
+		InsecureRegistries: []string{host},
+	}
+
+	// setup tests
+	tests := []struct {
+		repo string
+		want []string
+	}{
+		{ // paginated tags
+			repo: host + "/target/vela-kaniko",
+			want: []string{"latest", "v1.0.0", "v1.1.0"},
+		},
+		{ // repo not published yet
+			repo: host + "/target/new-repo",
+			want: []string{},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := r.ListTags(t.Context(), test.repo)
+		if err != nil {
+			t.Errorf("ListTags returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ListTags is %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestDocker_nextLink(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{
+			header: `</v2/octocat/hello-world/tags/list?last=b&n=2>; rel="next"`,
+			want:   "/v2/octocat/hello-world/tags/list?last=b&n=2",
+		},
+		{
+			header: `<https://example.com/prev>; rel="prev", <https://example.com/next>; rel="next"`,
+			want:   "https://example.com/next",
+		},
+		{
+			header: "",
+			want:   "",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := nextLink(test.header)
+
+		if got != test.want {
+			t.Errorf("nextLink is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestDocker_Registry_TagDigest(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup registry
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/target/vela-kaniko/manifests/1.2.3":
+			w.Header().Set("Docker-Content-Digest", "sha256:deadbeef")
+			w.WriteHeader(http.StatusOK)
+		case "/v2/target/private/manifests/1.2.3":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	r := &Registry{
+		Name:               host,
+		InsecureRegistries: []string{host},
+	}
+
+	// setup tests
+	tests := []struct {
+		repo    string
+		tag     string
+		want    string
+		wantErr error
+	}{
+		{repo: host + "/target/vela-kaniko", tag: "1.2.3", want: "sha256:deadbeef"},
+		{repo: host + "/target/vela-kaniko", tag: "1.2.4", want: ""},
+		{repo: host + "/target/private", tag: "1.2.3", wantErr: errUnauthorized},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := r.TagDigest(t.Context(), test.repo, test.tag)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("TagDigest returned err: %v, want %v", err, test.wantErr)
+		}
+
+		if got != test.want {
+			t.Errorf("TagDigest is %v, want %v", got, test.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,22 +13,34 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const kanikoBin = "/kaniko/executor"
+// kanikoBin is the path to the kaniko executor.
+var kanikoBin = "/kaniko/executor"
+
+// digestFile is the path the kaniko executor writes the digest of the image to,
+// which is in the kaniko directory so it is not included in the image.
+var digestFile = "/kaniko/digest"
 
 // execCmd is a helper function to
 // run the provided command.
 func execCmd(e *exec.Cmd) error {
 	logrus.Tracef("executing cmd %s", strings.Join(e.Args, " "))
 
+	// mask any secrets in the command output
+	stdout := secretMasker.Writer(os.Stdout)
+	stderr := secretMasker.Writer(os.Stderr)
+
 	// set command stdout to OS stdout
-	e.Stdout = os.Stdout
+	e.Stdout = stdout
 	// set command stderr to OS stderr
-	e.Stderr = os.Stderr
+	e.Stderr = stderr
 
 	// output "trace" string for command
-	fmt.Println("$", strings.Join(e.Args, " "))
+	fmt.Println("$", secretMasker.Mask(strings.Join(e.Args, " ")))
+
+	err := e.Run()
 
-	return e.Run()
+	// output any remaining partial lines
+	return errors.Join(err, stdout.Flush(), stderr.Flush())
 }
 
 // versionCmd is a helper function to output
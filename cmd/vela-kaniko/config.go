@@ -0,0 +1,278 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// defaultDockerConfigDir is the directory kaniko reads the
+// Docker config.json file from when DOCKER_CONFIG is not set.
+const defaultDockerConfigDir = "/kaniko/.docker"
+
+type (
+	// dockerConfig represents the Docker config.json file.
+	//
+	// https://docs.docker.com/reference/cli/docker/#docker-cli-configuration-file-configjson-properties
+	dockerConfig struct {
+		// authentication for each registry
+		Auths map[string]dockerAuth
+		// credential helpers to use for specific registries
+		CredHelpers map[string]string
+		// default credential store to use for all registries
+		CredsStore string
+		// other properties from an existing file that are preserved as-is
+		extra map[string]json.RawMessage
+	}
+
+	// dockerAuth represents a single entry in the auths section of the Docker config.json file.
+	dockerAuth struct {
+		Auth          string `json:"auth,omitempty"`
+		Username      string `json:"username,omitempty"`
+		Password      string `json:"password,omitempty"`
+		Email         string `json:"email,omitempty"`
+		ServerAddress string `json:"serveraddress,omitempty"`
+		IdentityToken string `json:"identitytoken,omitempty"`
+		RegistryToken string `json:"registrytoken,omitempty"`
+	}
+)
+
+// dockerConfigPath returns the full path to the Docker config.json file.
+func dockerConfigPath() string {
+	// check if the config directory is overridden
+	dir := os.Getenv("DOCKER_CONFIG")
+	if len(dir) == 0 {
+		dir = defaultDockerConfigDir
+	}
+
+	return filepath.Join(dir, "config.json")
+}
+
+// readDockerConfig parses the Docker config.json file at the path, if it exists.
+func readDockerConfig(a *afero.Afero, path string) (*dockerConfig, error) {
+	logrus.Tracef("reading existing registry configuration file %s", path)
+
+	config := new(dockerConfig)
+
+	data, err := a.ReadFile(path)
+	if err != nil {
+		// an absent file is the same as an empty one
+		if errors.Is(err, os.ErrNotExist) {
+			return config, nil
+		}
+
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse existing registry configuration file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// parseDockerConfig parses and validates a provided Docker config.json or containers auth.json document.
+func parseDockerConfig(data string) (*dockerConfig, error) {
+	config := new(dockerConfig)
+
+	// check if a document was provided
+	if len(strings.TrimSpace(data)) == 0 {
+		return config, nil
+	}
+
+	err := json.Unmarshal([]byte(data), config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse provided docker config: %w", err)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid provided docker config: %w", err)
+	}
+
+	return config, nil
+}
+
+// Validate verifies the dockerConfig is properly configured.
+func (c *dockerConfig) Validate() error {
+	// verify authentication or a credential helper is provided
+	if c.empty() {
+		return fmt.Errorf("no auths, credHelpers or credsStore provided")
+	}
+
+	// check each of the registry auths
+	for registry, auth := range c.Auths {
+		err := auth.Validate()
+		if err != nil {
+			return fmt.Errorf("%s: %w", registry, err)
+		}
+	}
+
+	// check each of the credential helpers
+	for registry, helper := range c.CredHelpers {
+		if len(helper) == 0 {
+			return fmt.Errorf("%s: no credential helper provided", registry)
+		}
+	}
+
+	return nil
+}
+
+// Validate verifies the dockerAuth is properly configured.
+func (a *dockerAuth) Validate() error {
+	// check if a basic authentication value is provided
+	if len(a.Auth) > 0 {
+		decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+		if err != nil {
+			return fmt.Errorf("auth value is not valid base64: %w", err)
+		}
+
+		// verify the value is in the format username:password
+		if !strings.Contains(string(decoded), ":") {
+			return fmt.Errorf("auth value is not a base64 encoded username:password")
+		}
+
+		return nil
+	}
+
+	// check if a token or username and password are provided
+	if len(a.IdentityToken) == 0 && len(a.RegistryToken) == 0 &&
+		(len(a.Username) == 0 || len(a.Password) == 0) {
+		return fmt.Errorf("no auth, username and password or token provided")
+	}
+
+	return nil
+}
+
+// empty checks if the config contains any authentication or credential helpers.
+func (c *dockerConfig) empty() bool {
+	return len(c.Auths) == 0 && len(c.CredHelpers) == 0 && len(c.CredsStore) == 0
+}
+
+// lookup returns the auth entry for the registry, matching
+// entries by their canonical key in the auths section.
+func (c *dockerConfig) lookup(registry string) (dockerAuth, bool) {
+	key := authKey(registry)
+
+	// check for an exact match first
+	if auth, ok := c.Auths[key]; ok {
+		return auth, true
+	}
+
+	for name, auth := range c.Auths {
+		if authKey(name) == key {
+			return auth, true
+		}
+	}
+
+	return dockerAuth{}, false
+}
+
+// merge adds the entries from the other config to the config.
+//
+// Entries from the other config take precedence, replacing any entry
+// for the same registry or other property, such as proxies, while
+// all other entries are preserved.
+func (c *dockerConfig) merge(other *dockerConfig) {
+	for registry, auth := range other.Auths {
+		if c.Auths == nil {
+			c.Auths = make(map[string]dockerAuth)
+		}
+
+		// remove any entry for the same registry under a different name
+		for name := range c.Auths {
+			if authKey(name) == authKey(registry) {
+				delete(c.Auths, name)
+			}
+		}
+
+		c.Auths[registry] = auth
+	}
+
+	for registry, helper := range other.CredHelpers {
+		if c.CredHelpers == nil {
+			c.CredHelpers = make(map[string]string)
+		}
+
+		c.CredHelpers[registry] = helper
+	}
+
+	if len(other.CredsStore) > 0 {
+		c.CredsStore = other.CredsStore
+	}
+
+	for key, value := range other.extra {
+		if c.extra == nil {
+			c.extra = make(map[string]json.RawMessage)
+		}
+
+		c.extra[key] = value
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c *dockerConfig) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]any)
+
+	// start from the preserved properties
+	for key, value := range c.extra {
+		fields[key] = value
+	}
+
+	if len(c.Auths) > 0 {
+		fields["auths"] = c.Auths
+	}
+
+	if len(c.CredHelpers) > 0 {
+		fields["credHelpers"] = c.CredHelpers
+	}
+
+	if len(c.CredsStore) > 0 {
+		fields["credsStore"] = c.CredsStore
+	}
+
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *dockerConfig) UnmarshalJSON(data []byte) error {
+	fields := make(map[string]json.RawMessage)
+
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range fields {
+		switch key {
+		case "auths":
+			err = json.Unmarshal(value, &c.Auths)
+		case "credHelpers":
+			err = json.Unmarshal(value, &c.CredHelpers)
+		case "credsStore":
+			err = json.Unmarshal(value, &c.CredsStore)
+		default:
+			if c.extra == nil {
+				c.extra = make(map[string]json.RawMessage)
+			}
+
+			c.extra[key] = value
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDocker_dockerConfigPath(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "default",
+			config: "",
+			want:   "/kaniko/.docker/config.json",
+		},
+		{
+			name:   "docker config",
+			config: "/root/.docker/",
+			want:   "/root/.docker/config.json",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("DOCKER_CONFIG", test.config)
+
+			got := dockerConfigPath()
+
+			if got != test.want {
+				t.Errorf("dockerConfigPath is %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_dockerConfig_merge(t *testing.T) {
+	// setup types
+	c := &dockerConfig{
+		Auths: map[string]dockerAuth{
+			"index.docker.io": {Auth: "old"},
+			"quay.io":         {Auth: "quay"},
+		},
+		CredsStore: "desktop",
+		extra: map[string]json.RawMessage{
+			"proxies":     json.RawMessage(`{"default": {"httpProxy": "http://old:3128"}}`),
+			"HttpHeaders": json.RawMessage(`{"User-Agent": "octocat"}`),
+		},
+	}
+
+	other := &dockerConfig{
+		Auths: map[string]dockerAuth{
+			"index.docker.io": {Auth: "new"},
+		},
+		CredHelpers: map[string]string{"gcr.io": "gcr"},
+		extra: map[string]json.RawMessage{
+			"proxies": json.RawMessage(`{"default": {"httpProxy": "http://new:3128"}}`),
+		},
+	}
+
+	want := &dockerConfig{
+		Auths: map[string]dockerAuth{
+			"index.docker.io": {Auth: "new"},
+			"quay.io":         {Auth: "quay"},
+		},
+		CredHelpers: map[string]string{"gcr.io": "gcr"},
+		CredsStore:  "desktop",
+		extra: map[string]json.RawMessage{
+			"proxies":     json.RawMessage(`{"default": {"httpProxy": "http://new:3128"}}`),
+			"HttpHeaders": json.RawMessage(`{"User-Agent": "octocat"}`),
+		},
+	}
+
+	c.merge(other)
+
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("merge is %v, want %v", c, want)
+	}
+}
+
+func TestDocker_parseDockerConfig(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		data    string
+		failure bool
+	}{
+		{
+			name:    "empty",
+			data:    "",
+			failure: false,
+		},
+		{
+			name:    "docker config",
+			data:    `{"auths": {"index.docker.io": {"auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"}}, "credHelpers": {"gcr.io": "gcr"}}`,
+			failure: false,
+		},
+		{
+			name:    "containers auth",
+			data:    `{"auths": {"quay.io/octocat": {"auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"}}}`,
+			failure: false,
+		},
+		{
+			name:    "identity token",
+			data:    `{"auths": {"octocat.azurecr.io": {"identitytoken": "superSecretToken"}}}`,
+			failure: false,
+		},
+		{
+			name:    "invalid json",
+			data:    `{"auths":`,
+			failure: true,
+		},
+		{
+			name:    "invalid auths",
+			data:    `{"auths": ["index.docker.io"]}`,
+			failure: true,
+		},
+		{
+			name:    "no auths",
+			data:    `{"proxies": {}}`,
+			failure: true,
+		},
+		{
+			name:    "invalid base64",
+			data:    `{"auths": {"index.docker.io": {"auth": "not base64!"}}}`,
+			failure: true,
+		},
+		{
+			name:    "no separator",
+			data:    `{"auths": {"index.docker.io": {"auth": "b2N0b2NhdA=="}}}`,
+			failure: true,
+		},
+		{
+			name:    "empty auth",
+			data:    `{"auths": {"index.docker.io": {}}}`,
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseDockerConfig(test.data)
+
+			if test.failure {
+				if err == nil {
+					t.Errorf("parseDockerConfig should have returned err")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Errorf("parseDockerConfig returned err: %v", err)
+			}
+		})
+	}
+}
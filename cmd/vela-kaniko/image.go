@@ -3,15 +3,25 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
+	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
 )
 
 // Image represents the plugin configuration for image information.
 type Image struct {
 	// variables passed to the image at build-time
 	Args []string
+	// path to a dotenv or JSON file with additional build-time variables
+	ArgsFile string
+	// keys of build-time variables masked in output
+	MaskArgs []string
 	// path to the context for building the image
 	Context string
 	// path to the file for building the image
@@ -22,6 +32,12 @@ type Image struct {
 	ForceBuildMetadata bool
 	// custom platform for image
 	CustomPlatform string
+	// platforms for building the image published as an image index
+	Platforms []string
+	// specs for building several images in the same step
+	Builds []*Spec
+	// enable building the remaining images after a build fails
+	ContinueOnError bool
 }
 
 // Validate verifies the Image is properly configured.
@@ -38,5 +54,123 @@ func (i *Image) Validate() error {
 		return fmt.Errorf("no image dockerfile provided")
 	}
 
+	// check if platforms are provided
+	if len(i.Platforms) > 0 {
+		// verify a custom platform is not also provided
+		if len(i.CustomPlatform) > 0 {
+			return fmt.Errorf("custom platform and platforms must not both be provided")
+		}
+
+		for _, platform := range i.Platforms {
+			_, err := parsePlatform(platform)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
+
+// ReadArgsFile adds the build-time variables from the args file to the image build args.
+//
+// The file is parsed the same as the build args parameter when it starts with a
+// brace or bracket, such as a JSON map or list, and otherwise as a dotenv file. A
+// build arg provided inline takes precedence over one with the same key from the file.
+func (i *Image) ReadArgsFile() error {
+	logrus.Debugf("reading build args from file %s", i.ArgsFile)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	data, err := a.ReadFile(i.ArgsFile)
+	if err != nil {
+		return fmt.Errorf("unable to read build args file %s: %w", i.ArgsFile, err)
+	}
+
+	var pairs []string
+
+	trimmed := bytes.TrimSpace(data)
+
+	// check if the file contains a map or list
+	if bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")) {
+		pairs, err = parseKeyValues(string(data))
+	} else {
+		pairs, err = parseDotenv(data)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to parse build args file %s: %w", i.ArgsFile, err)
+	}
+
+	// capture the keys of the build args provided inline
+	inline := []string{}
+
+	for _, arg := range i.Args {
+		key, _, _ := strings.Cut(arg, "=")
+
+		inline = append(inline, strings.TrimSpace(key))
+	}
+
+	args := []string{}
+
+	for _, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+
+		// skip build args that are overridden inline
+		if slices.Contains(inline, key) {
+			continue
+		}
+
+		args = append(args, pair)
+	}
+
+	// add build args from file ahead of those provided inline
+	i.Args = append(args, i.Args...)
+
+	return nil
+}
+
+// parseDotenv parses the KEY=VALUE pairs from a dotenv file, sorted by key.
+//
+// Unquoted and double quoted values expand $VAR and ${VAR} from the variables
+// defined earlier in the file, so a value containing $ must be single quoted.
+func parseDotenv(data []byte) ([]string, error) {
+	values, err := godotenv.UnmarshalBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := []string{}
+
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, values[key]))
+	}
+
+	return pairs, nil
+}
+
+// secrets returns the values of the build-time variables to mask in output.
+func (i *Image) secrets() []string {
+	secrets := []string{}
+
+	args := slices.Clone(i.Args)
+
+	// include the build-time variables for each build spec
+	for _, s := range i.Builds {
+		args = append(args, s.args()...)
+	}
+
+	for _, arg := range args {
+		key, value, _ := strings.Cut(arg, "=")
+
+		// check if the build arg is masked
+		if slices.Contains(i.MaskArgs, strings.TrimSpace(key)) {
+			secrets = append(secrets, value)
+		}
+	}
+
+	return secrets
+}
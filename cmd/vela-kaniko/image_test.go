@@ -2,7 +2,12 @@
 
 package main
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
 
 func TestDocker_Image_Validate(t *testing.T) {
 	// setup types
@@ -47,3 +52,132 @@ func TestDocker_Image_Validate_NoDockerfile(t *testing.T) {
 		t.Errorf("Validate should have returned err")
 	}
 }
+
+func TestDocker_Image_Validate_Platforms(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		image   *Image
+		failure bool
+	}{
+		{
+			image:   &Image{Platforms: []string{"linux/amd64", "linux/arm64/v8"}},
+			failure: false,
+		},
+		{
+			image:   &Image{Platforms: []string{"linux/amd64", "arm64"}},
+			failure: true,
+		},
+		{
+			image:   &Image{Platforms: []string{"linux/amd64"}, CustomPlatform: "linux/arm64"},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		test.image.Context = "."
+		test.image.Dockerfile = "Dockerfile"
+
+		err := test.image.Validate()
+
+		if test.failure && err == nil {
+			t.Errorf("Validate for %v should have returned err", test.image.Platforms)
+		}
+
+		if !test.failure && err != nil {
+			t.Errorf("Validate for %v returned err: %v", test.image.Platforms, err)
+		}
+	}
+}
+
+func TestDocker_Image_ReadArgsFile(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "dotenv",
+			data: "# generated by the version step\nVERSION=1.2.3\nexport CHECKSUM=\"a,b=c\"\nfoo=file\n",
+			want: []string{"CHECKSUM=a,b=c", "VERSION=1.2.3", "foo=bar"},
+		},
+		{
+			name: "json",
+			data: `{"VERSION": "1.2.3", "CHECKSUM": "a,b=c", "foo": "file"}`,
+			want: []string{"CHECKSUM=a,b=c", "VERSION=1.2.3", "foo=bar"},
+		},
+		{
+			name: "json with number",
+			data: `{"VERSION": "1.2.3", "CHECKSUM": "a,b=c", "PORT": 8080, "DEBUG": false}`,
+			want: []string{"CHECKSUM=a,b=c", "DEBUG=false", "PORT=8080", "VERSION=1.2.3", "foo=bar"},
+		},
+		{
+			name: "json list",
+			data: `["VERSION=1.2.3", "CHECKSUM=a,b=c", {"PORT": 8080}, "foo=file"]`,
+			want: []string{"VERSION=1.2.3", "CHECKSUM=a,b=c", "PORT=8080", "foo=bar"},
+		},
+		{
+			name: "dotenv with single quoted value",
+			data: "VERSION=1.2.3\nCHECKSUM='a,b=c'\nHASH='$2a$10$abc'\n",
+			want: []string{"CHECKSUM=a,b=c", "HASH=$2a$10$abc", "VERSION=1.2.3", "foo=bar"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			err := afero.WriteFile(appFS, "/vela/src/build.env", []byte(test.data), 0644)
+			if err != nil {
+				t.Errorf("WriteFile returned err: %v", err)
+			}
+
+			i := &Image{
+				Args:     []string{"foo=bar"},
+				ArgsFile: "/vela/src/build.env",
+			}
+
+			err = i.ReadArgsFile()
+			if err != nil {
+				t.Errorf("ReadArgsFile returned err: %v", err)
+			}
+
+			if !reflect.DeepEqual(i.Args, test.want) {
+				t.Errorf("ReadArgsFile is %v, want %v", i.Args, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Image_ReadArgsFile_Invalid(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	err := afero.WriteFile(appFS, "/vela/src/build.json", []byte(`{"VERSION": 1`), 0644)
+	if err != nil {
+		t.Errorf("WriteFile returned err: %v", err)
+	}
+
+	err = afero.WriteFile(appFS, "/vela/src/list.json", []byte(`["VERSION"]`), 0644)
+	if err != nil {
+		t.Errorf("WriteFile returned err: %v", err)
+	}
+
+	// setup tests
+	tests := []string{"/vela/src/build.json", "/vela/src/list.json", "/vela/src/missing.env"}
+
+	// run tests
+	for _, test := range tests {
+		i := &Image{
+			ArgsFile: test,
+		}
+
+		err = i.ReadArgsFile()
+		if err == nil {
+			t.Errorf("ReadArgsFile for %s should have returned err", test)
+		}
+	}
+}
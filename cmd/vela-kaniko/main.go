@@ -8,9 +8,12 @@ import (
 	"fmt"
 	"net/mail"
 	"os"
-	"strings"
+	"slices"
 	"time"
 
+	// embed the timezone database, since the image may not provide one
+	_ "time/tzdata"
+
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v3"
 
@@ -64,6 +67,28 @@ func main() {
 		},
 
 		// Build Flags
+		&cli.StringFlag{
+			Name:  "build.branch",
+			Usage: "branch for build",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_BRANCH"),
+				cli.EnvVar("KANIKO_BRANCH"),
+				cli.EnvVar("VELA_BUILD_BRANCH"),
+				cli.File("/vela/parameters/kaniko/branch"),
+				cli.File("/vela/secrets/kaniko/branch"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "build.default_branch",
+			Usage: "default branch for the repository",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_DEFAULT_BRANCH"),
+				cli.EnvVar("KANIKO_DEFAULT_BRANCH"),
+				cli.EnvVar("VELA_REPO_BRANCH"),
+				cli.File("/vela/parameters/kaniko/default_branch"),
+				cli.File("/vela/secrets/kaniko/default_branch"),
+			),
+		},
 		&cli.StringFlag{
 			Name:  "build.event",
 			Usage: "event triggered for build",
@@ -75,6 +100,67 @@ func main() {
 				cli.File("/vela/secrets/kaniko/event"),
 			),
 		},
+		&cli.StringFlag{
+			Name:  "build.pull_request",
+			Usage: "number of the pull request for build (only populated for pull_request events)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_PULL_REQUEST"),
+				cli.EnvVar("KANIKO_PULL_REQUEST"),
+				cli.EnvVar("VELA_PULL_REQUEST"),
+				cli.File("/vela/parameters/kaniko/pull_request"),
+				cli.File("/vela/secrets/kaniko/pull_request"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "build.pull_request_source",
+			Usage: "source branch of the pull request for build (only populated for pull_request events)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_PULL_REQUEST_SOURCE"),
+				cli.EnvVar("KANIKO_PULL_REQUEST_SOURCE"),
+				cli.EnvVar("VELA_PULL_REQUEST_SOURCE"),
+				cli.File("/vela/parameters/kaniko/pull_request_source"),
+				cli.File("/vela/secrets/kaniko/pull_request_source"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "build.pull_request_target",
+			Usage: "target branch of the pull request for build (only populated for pull_request events)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_PULL_REQUEST_TARGET"),
+				cli.EnvVar("KANIKO_PULL_REQUEST_TARGET"),
+				cli.EnvVar("VELA_PULL_REQUEST_TARGET"),
+				cli.File("/vela/parameters/kaniko/pull_request_target"),
+				cli.File("/vela/secrets/kaniko/pull_request_target"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "build.deployment",
+			Usage: "target environment of the deployment for build (only populated for deployment events)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_DEPLOYMENT"),
+				cli.EnvVar("KANIKO_DEPLOYMENT"),
+				cli.EnvVar("VELA_DEPLOYMENT"),
+				cli.File("/vela/parameters/kaniko/deployment"),
+				cli.File("/vela/secrets/kaniko/deployment"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "build.schedule",
+			Usage: "name of the schedule for build (only populated for schedule events)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_SCHEDULE"),
+				cli.EnvVar("KANIKO_SCHEDULE"),
+				cli.File("/vela/parameters/kaniko/schedule"),
+				cli.File("/vela/secrets/kaniko/schedule"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "build.message",
+			Usage: "message for build",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("VELA_BUILD_MESSAGE"),
+			),
+		},
 		&cli.StringFlag{
 			Name:  "build.sha",
 			Usage: "commit SHA-1 hash for build",
@@ -172,6 +258,16 @@ func main() {
 				cli.File("/vela/secrets/kaniko/log_timestamps"),
 			),
 		},
+		&cli.BoolFlag{
+			Name:  "build.reproducible",
+			Usage: "strip timestamps out of the image to make it reproducible",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_REPRODUCIBLE"),
+				cli.EnvVar("KANIKO_REPRODUCIBLE"),
+				cli.File("/vela/parameters/kaniko/reproducible"),
+				cli.File("/vela/secrets/kaniko/reproducible"),
+			),
+		},
 
 		// Image Flags
 		&cli.StringFlag{
@@ -184,6 +280,26 @@ func main() {
 				cli.File("/vela/secrets/kaniko/build_args"),
 			),
 		},
+		&cli.StringFlag{
+			Name:  "image.build_args_file",
+			Usage: "path to a dotenv or JSON file with variables passed to the image at build-time",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_BUILD_ARGS_FILE"),
+				cli.EnvVar("KANIKO_BUILD_ARGS_FILE"),
+				cli.File("/vela/parameters/kaniko/build_args_file"),
+				cli.File("/vela/secrets/kaniko/build_args_file"),
+			),
+		},
+		&cli.StringSliceFlag{
+			Name:  "image.mask_build_args",
+			Usage: "keys of build-time variables to mask in output",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_MASK_BUILD_ARGS"),
+				cli.EnvVar("KANIKO_MASK_BUILD_ARGS"),
+				cli.File("/vela/parameters/kaniko/mask_build_args"),
+				cli.File("/vela/secrets/kaniko/mask_build_args"),
+			),
+		},
 		&cli.StringFlag{
 			Name:  "image.context",
 			Value: ".",
@@ -236,6 +352,36 @@ func main() {
 				cli.File("/vela/secrets/kaniko/custom_platform"),
 			),
 		},
+		&cli.StringSliceFlag{
+			Name:  "image.platforms",
+			Usage: "platforms for building the image published as an image index",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_PLATFORMS"),
+				cli.EnvVar("KANIKO_PLATFORMS"),
+				cli.File("/vela/parameters/kaniko/platforms"),
+				cli.File("/vela/secrets/kaniko/platforms"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "image.builds",
+			Usage: "JSON list of specs for building several images in the same step",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_BUILDS"),
+				cli.EnvVar("KANIKO_BUILDS"),
+				cli.File("/vela/parameters/kaniko/builds"),
+				cli.File("/vela/secrets/kaniko/builds"),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "image.continue_on_error",
+			Usage: "enables building the remaining images after a build fails",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CONTINUE_ON_ERROR"),
+				cli.EnvVar("KANIKO_CONTINUE_ON_ERROR"),
+				cli.File("/vela/parameters/kaniko/continue_on_error"),
+				cli.File("/vela/secrets/kaniko/continue_on_error"),
+			),
+		},
 
 		// Registry Flags
 		&cli.BoolFlag{
@@ -259,9 +405,9 @@ func main() {
 				cli.File("/vela/secrets/kaniko/registry"),
 			),
 		},
-		&cli.StringFlag{
+		&cli.StringSliceFlag{
 			Name:  "registry.mirror",
-			Usage: "name of the mirror registry to use instead of index.docker.io",
+			Usage: "names of the mirror registries to use instead of index.docker.io, tried in order",
 			Sources: cli.NewValueSourceChain(
 				cli.EnvVar("PARAMETER_MIRROR"),
 				cli.EnvVar("KANIKO_MIRROR"),
@@ -269,6 +415,36 @@ func main() {
 				cli.File("/vela/secrets/kaniko/mirror"),
 			),
 		},
+		&cli.StringFlag{
+			Name:  "registry.mirror_username",
+			Usage: "user name for communication with the mirror registries",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_MIRROR_USERNAME"),
+				cli.EnvVar("KANIKO_MIRROR_USERNAME"),
+				cli.File("/vela/parameters/kaniko/mirror_username"),
+				cli.File("/vela/secrets/kaniko/mirror_username"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "registry.mirror_password",
+			Usage: "password for communication with the mirror registries",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_MIRROR_PASSWORD"),
+				cli.EnvVar("KANIKO_MIRROR_PASSWORD"),
+				cli.File("/vela/parameters/kaniko/mirror_password"),
+				cli.File("/vela/secrets/kaniko/mirror_password"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "registry.mirror_token",
+			Usage: "bearer token for communication with the mirror registries",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_MIRROR_TOKEN"),
+				cli.EnvVar("KANIKO_MIRROR_TOKEN"),
+				cli.File("/vela/parameters/kaniko/mirror_token"),
+				cli.File("/vela/secrets/kaniko/mirror_token"),
+			),
+		},
 		&cli.StringFlag{
 			Name:  "registry.username",
 			Usage: "user name for communication with the registry",
@@ -293,6 +469,86 @@ func main() {
 				cli.File("/vela/secrets/managed-auth/password"),
 			),
 		},
+		&cli.StringFlag{
+			Name:  "registry.identity_token",
+			Usage: "OAuth refresh token for communication with the registry",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_IDENTITY_TOKEN"),
+				cli.EnvVar("KANIKO_IDENTITY_TOKEN"),
+				cli.File("/vela/parameters/kaniko/identity_token"),
+				cli.File("/vela/secrets/kaniko/identity_token"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "registry.registry_token",
+			Usage: "bearer token for communication with the registry",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_REGISTRY_TOKEN"),
+				cli.EnvVar("KANIKO_REGISTRY_TOKEN"),
+				cli.File("/vela/parameters/kaniko/registry_token"),
+				cli.File("/vela/secrets/kaniko/registry_token"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "registry.credentials",
+			Usage: "JSON list of registry, username and password entries for additional registries",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CREDENTIALS"),
+				cli.EnvVar("KANIKO_CREDENTIALS"),
+				cli.File("/vela/parameters/kaniko/credentials"),
+				cli.File("/vela/secrets/kaniko/credentials"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "registry.cred_helpers",
+			Usage: "JSON map of registry names to the credential helper to use for them",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CRED_HELPERS"),
+				cli.EnvVar("KANIKO_CRED_HELPERS"),
+				cli.File("/vela/parameters/kaniko/cred_helpers"),
+				cli.File("/vela/secrets/kaniko/cred_helpers"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "registry.creds_store",
+			Usage: "default credential helper to use for all registries",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CREDS_STORE"),
+				cli.EnvVar("KANIKO_CREDS_STORE"),
+				cli.File("/vela/parameters/kaniko/creds_store"),
+				cli.File("/vela/secrets/kaniko/creds_store"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "registry.docker_config",
+			Usage: "full Docker config.json or containers auth.json document for communication with registries",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_DOCKER_CONFIG"),
+				cli.EnvVar("KANIKO_DOCKER_CONFIG"),
+				cli.File("/vela/parameters/kaniko/docker_config"),
+				cli.File("/vela/secrets/kaniko/docker_config"),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "registry.preflight",
+			Usage: "enables verifying authentication with the registry before building the image",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_PREFLIGHT"),
+				cli.EnvVar("KANIKO_PREFLIGHT"),
+				cli.File("/vela/parameters/kaniko/preflight"),
+				cli.File("/vela/secrets/kaniko/preflight"),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "registry.preflight_push",
+			Usage: "enables verifying push permission for the repo and cache repo before building the image",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_PREFLIGHT_PUSH"),
+				cli.EnvVar("KANIKO_PREFLIGHT_PUSH"),
+				cli.File("/vela/parameters/kaniko/preflight_push"),
+				cli.File("/vela/secrets/kaniko/preflight_push"),
+			),
+		},
 		&cli.IntFlag{
 			Name:  "registry.push_retry",
 			Usage: "number of retries for pushing an image to a remote destination",
@@ -345,6 +601,148 @@ func main() {
 				cli.File("/vela/secrets/kaniko/auto_tag"),
 			),
 		},
+		&cli.BoolFlag{
+			Name:  "repo.auto_tag_branch",
+			Usage: "enables automatically tagging the image with the branch for push events",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_AUTO_TAG_BRANCH"),
+				cli.EnvVar("KANIKO_AUTO_TAG_BRANCH"),
+				cli.File("/vela/parameters/kaniko/auto_tag_branch"),
+				cli.File("/vela/secrets/kaniko/auto_tag_branch"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.pull_request_tag",
+			Usage: "template for the tag of the image for pull_request events with auto tagging",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_PULL_REQUEST_TAG"),
+				cli.EnvVar("KANIKO_PULL_REQUEST_TAG"),
+				cli.File("/vela/parameters/kaniko/pull_request_tag"),
+				cli.File("/vela/secrets/kaniko/pull_request_tag"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.deployment_tag",
+			Usage: "template for the tag of the image for deployment events with auto tagging",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_DEPLOYMENT_TAG"),
+				cli.EnvVar("KANIKO_DEPLOYMENT_TAG"),
+				cli.File("/vela/parameters/kaniko/deployment_tag"),
+				cli.File("/vela/secrets/kaniko/deployment_tag"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.schedule_tag",
+			Usage: "template for the tag of the image for schedule events with auto tagging",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_SCHEDULE_TAG"),
+				cli.EnvVar("KANIKO_SCHEDULE_TAG"),
+				cli.File("/vela/parameters/kaniko/schedule_tag"),
+				cli.File("/vela/secrets/kaniko/schedule_tag"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.calver_layout",
+			Usage: "Go time layout for the calendar version tag of the image with auto tagging",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CALVER_LAYOUT"),
+				cli.EnvVar("KANIKO_CALVER_LAYOUT"),
+				cli.File("/vela/parameters/kaniko/calver_layout"),
+				cli.File("/vela/secrets/kaniko/calver_layout"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.calver_timezone",
+			Value: "UTC",
+			Usage: "timezone for the calendar version tag of the image",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CALVER_TIMEZONE"),
+				cli.EnvVar("KANIKO_CALVER_TIMEZONE"),
+				cli.File("/vela/parameters/kaniko/calver_timezone"),
+				cli.File("/vela/secrets/kaniko/calver_timezone"),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "repo.calver_build_number",
+			Usage: "enables adding the build number to the calendar version tag of the image",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_CALVER_BUILD_NUMBER"),
+				cli.EnvVar("KANIKO_CALVER_BUILD_NUMBER"),
+				cli.File("/vela/parameters/kaniko/calver_build_number"),
+				cli.File("/vela/secrets/kaniko/calver_build_number"),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "repo.sanitize_tags",
+			Usage: "enables rewriting invalid tags into valid docker tags instead of failing",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_SANITIZE_TAGS"),
+				cli.EnvVar("KANIKO_SANITIZE_TAGS"),
+				cli.File("/vela/parameters/kaniko/sanitize_tags"),
+				cli.File("/vela/secrets/kaniko/sanitize_tags"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.immutable_tags",
+			Usage: "regular expression for tags that must not be overwritten once published",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_IMMUTABLE_TAGS"),
+				cli.EnvVar("KANIKO_IMMUTABLE_TAGS"),
+				cli.File("/vela/parameters/kaniko/immutable_tags"),
+				cli.File("/vela/secrets/kaniko/immutable_tags"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.immutable_tags_action",
+			Value: "fail",
+			Usage: "action for an immutable tag that is already published - options (fail|skip)",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_IMMUTABLE_TAGS_ACTION"),
+				cli.EnvVar("KANIKO_IMMUTABLE_TAGS_ACTION"),
+				cli.File("/vela/parameters/kaniko/immutable_tags_action"),
+				cli.File("/vela/secrets/kaniko/immutable_tags_action"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.tag_prefix",
+			Usage: "prefix added to each of the tags of the image",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_TAG_PREFIX"),
+				cli.EnvVar("KANIKO_TAG_PREFIX"),
+				cli.File("/vela/parameters/kaniko/tag_prefix"),
+				cli.File("/vela/secrets/kaniko/tag_prefix"),
+			),
+		},
+		&cli.StringFlag{
+			Name:  "repo.tag_suffix",
+			Usage: "suffix added to each of the tags of the image",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_TAG_SUFFIX"),
+				cli.EnvVar("KANIKO_TAG_SUFFIX"),
+				cli.File("/vela/parameters/kaniko/tag_suffix"),
+				cli.File("/vela/secrets/kaniko/tag_suffix"),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "repo.semver_tags",
+			Usage: "enables expanding a semantic version build tag into major, minor and patch tags",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_SEMVER_TAGS"),
+				cli.EnvVar("KANIKO_SEMVER_TAGS"),
+				cli.File("/vela/parameters/kaniko/semver_tags"),
+				cli.File("/vela/secrets/kaniko/semver_tags"),
+			),
+		},
+		&cli.BoolFlag{
+			Name:  "repo.semver_latest",
+			Usage: "enables adding the latest tag for a semantic version build tag",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_SEMVER_LATEST"),
+				cli.EnvVar("KANIKO_SEMVER_LATEST"),
+				cli.File("/vela/parameters/kaniko/semver_latest"),
+				cli.File("/vela/secrets/kaniko/semver_latest"),
+			),
+		},
 		&cli.BoolFlag{
 			Name:  "repo.cache",
 			Usage: "enables caching of each layer for the image",
@@ -427,6 +825,16 @@ func main() {
 				cli.File("/vela/secrets/kaniko/labels"),
 			),
 		},
+		&cli.StringFlag{
+			Name:  "repo.tags_file",
+			Usage: "path to a file with comma or newline separated tags of the image",
+			Sources: cli.NewValueSourceChain(
+				cli.EnvVar("PARAMETER_TAGS_FILE"),
+				cli.EnvVar("KANIKO_TAGS_FILE"),
+				cli.File("/vela/parameters/kaniko/tags_file"),
+				cli.File("/vela/secrets/kaniko/tags_file"),
+			),
+		},
 		&cli.StringFlag{
 			Name:  "repo.topics_filter",
 			Usage: "filter to restrict which repository topics to include in label",
@@ -494,6 +902,12 @@ func main() {
 
 // run executes the plugin based off the configuration provided.
 func run(ctx context.Context, c *cli.Command) error {
+	// mask any secrets in the log output for the plugin
+	logrus.SetFormatter(&maskFormatter{
+		Formatter: logrus.StandardLogger().Formatter,
+		masker:    secretMasker,
+	})
+
 	// set the log level for the plugin
 	switch c.String("log.level") {
 	case "t", "trace", "Trace", "TRACE":
@@ -520,95 +934,152 @@ func run(ctx context.Context, c *cli.Command) error {
 		"registry": "https://hub.docker.com/r/target/vela-kaniko",
 	}).Info("Vela Kaniko Plugin")
 
-	// target type for build args
-	var buildArgs []string
+	// parse the build args from a JSON map or list, YAML list or comma separated values
+	buildArgs, err := parseKeyValues(c.String("image.build_args"))
+	if err != nil {
+		return fmt.Errorf("unable to parse image build args: %w", err)
+	}
+
+	// parse the custom labels from a JSON map or list, YAML list or comma separated values
+	customLabels, err := parseKeyValues(c.String("label.custom"))
+	if err != nil {
+		return fmt.Errorf("unable to parse custom labels: %w", err)
+	}
 
-	argsStr := c.String("image.build_args")
-	if len(argsStr) > 0 {
-		buildArgsMap := make(map[string]string)
+	// target type for additional registry credentials
+	var credentials []*Credential
 
-		// attempt to unmarshal to map
-		err := json.Unmarshal([]byte(argsStr), &buildArgsMap)
+	credsStr := c.String("registry.credentials")
+	if len(credsStr) > 0 {
+		// attempt to unmarshal to list of credentials
+		err := json.Unmarshal([]byte(credsStr), &credentials)
 		if err != nil {
-			// fall back on splitting the string
-			buildArgs = strings.Split(argsStr, ",")
-		} else {
-			// iterate through the build args map
-			for key, value := range buildArgsMap {
-				// add the build arg to the build args
-				buildArgs = append(buildArgs, fmt.Sprintf("%s=%s", key, value))
-			}
+			return fmt.Errorf("unable to parse registry credentials: %w", err)
+		}
+
+		// verify each entry is provided, since a null unmarshals to a nil entry
+		if slices.Contains(credentials, nil) {
+			return fmt.Errorf("unable to parse registry credentials: null entry provided")
 		}
 	}
 
-	// target type for custom labels
-	var customLabels []string
+	// target type for build specs
+	var builds []*Spec
+
+	buildsStr := c.String("image.builds")
+	if len(buildsStr) > 0 {
+		// attempt to unmarshal to list of build specs
+		err := json.Unmarshal([]byte(buildsStr), &builds)
+		if err != nil {
+			return fmt.Errorf("unable to parse image builds: %w", err)
+		}
+	}
 
-	labelsStr := c.String("label.custom")
-	if len(labelsStr) > 0 {
-		customLabelsMap := make(map[string]string)
+	// target type for credential helpers
+	credHelpers := make(map[string]string)
 
+	helpersStr := c.String("registry.cred_helpers")
+	if len(helpersStr) > 0 {
 		// attempt to unmarshal to map
-		err := json.Unmarshal([]byte(labelsStr), &customLabelsMap)
+		err := json.Unmarshal([]byte(helpersStr), &credHelpers)
 		if err != nil {
-			// fall back on splitting the string
-			customLabels = strings.Split(labelsStr, ",")
-		} else {
-			// iterate through the custom labels map
-			for key, value := range customLabelsMap {
-				// add the custom label to the custom labels
-				customLabels = append(customLabels, fmt.Sprintf("%s=%s", key, value))
-			}
+			return fmt.Errorf("unable to parse registry credential helpers: %w", err)
 		}
 	}
 
+	// capture the name of the schedule for a schedule event,
+	// which Vela only provides in the message for the build
+	schedule := c.String("build.schedule")
+	if len(schedule) == 0 && c.String("build.event") == "schedule" {
+		schedule = parseSchedule(c.String("build.message"))
+	}
+
 	// create the plugin
 	p := &Plugin{
 		// build configuration
 		Build: &Build{
-			Event:          c.String("build.event"),
-			Sha:            c.String("build.sha"),
-			SnapshotMode:   c.String("build.snapshot_mode"),
-			Tag:            c.String("build.tag"),
-			UseNewRun:      c.Bool("build.use_new_run"),
-			TarPath:        c.String("build.tar_path"),
-			SingleSnapshot: c.Bool("build.single_snapshot"),
-			IgnoreVarRun:   c.Bool("build.ignore_var_run"),
-			IgnorePath:     c.StringSlice("build.ignore_path"),
-			LogTimestamp:   c.Bool("build.log_timestamps"),
+			Branch:            c.String("build.branch"),
+			DefaultBranch:     c.String("build.default_branch"),
+			Event:             c.String("build.event"),
+			PullRequest:       c.String("build.pull_request"),
+			PullRequestSource: c.String("build.pull_request_source"),
+			PullRequestTarget: c.String("build.pull_request_target"),
+			Deployment:        c.String("build.deployment"),
+			Schedule:          schedule,
+			Sha:               c.String("build.sha"),
+			SnapshotMode:      c.String("build.snapshot_mode"),
+			Tag:               c.String("build.tag"),
+			UseNewRun:         c.Bool("build.use_new_run"),
+			TarPath:           c.String("build.tar_path"),
+			SingleSnapshot:    c.Bool("build.single_snapshot"),
+			IgnoreVarRun:      c.Bool("build.ignore_var_run"),
+			IgnorePath:        c.StringSlice("build.ignore_path"),
+			LogTimestamp:      c.Bool("build.log_timestamps"),
+			Reproducible:      c.Bool("build.reproducible"),
 		},
 		// image configuration
 		Image: &Image{
 			Args:               buildArgs,
+			ArgsFile:           c.String("image.build_args_file"),
+			MaskArgs:           c.StringSlice("image.mask_build_args"),
 			Context:            c.String("image.context"),
 			Dockerfile:         c.String("image.dockerfile"),
 			Target:             c.String("image.target"),
 			ForceBuildMetadata: c.Bool("image.force_build_metadata"),
 			CustomPlatform:     c.String("image.custom_platform"),
+			Platforms:          c.StringSlice("image.platforms"),
+			Builds:             builds,
+			ContinueOnError:    c.Bool("image.continue_on_error"),
 		},
 		// registry configuration
 		Registry: &Registry{
 			DryRun:             c.Bool("registry.dry_run"),
 			Name:               c.String("registry.name"),
-			Mirror:             c.String("registry.mirror"),
+			Mirrors:            c.StringSlice("registry.mirror"),
+			MirrorUsername:     c.String("registry.mirror_username"),
+			MirrorPassword:     c.String("registry.mirror_password"),
+			MirrorToken:        c.String("registry.mirror_token"),
 			Username:           c.String("registry.username"),
 			Password:           c.String("registry.password"),
+			IdentityToken:      c.String("registry.identity_token"),
+			RegistryToken:      c.String("registry.registry_token"),
+			Credentials:        credentials,
+			CredHelpers:        credHelpers,
+			CredsStore:         c.String("registry.creds_store"),
+			DockerConfig:       c.String("registry.docker_config"),
 			PushRetry:          c.Int("registry.push_retry"),
 			InsecureRegistries: c.StringSlice("registry.insecure_registries"),
 			InsecurePull:       c.Bool("registry.insecure_pull"),
 			InsecurePush:       c.Bool("registry.insecure_push"),
+			Preflight:          c.Bool("registry.preflight"),
+			PreflightPush:      c.Bool("registry.preflight_push"),
 		},
 		// repo configuration
 		Repo: &Repo{
-			AutoTag:           c.Bool("repo.auto_tag"),
-			Cache:             c.Bool("repo.cache"),
-			CacheName:         c.String("repo.cache_name"),
-			Compression:       c.String("repo.compression"),
-			CompressionLevel:  c.Int("repo.compression_level"),
-			CompressedCaching: c.Bool("repo.compressed_caching"),
-			Name:              c.String("repo.name"),
-			Tags:              c.StringSlice("repo.tags"),
-			TopicsFilter:      c.String("repo.topics_filter"),
+			AutoTag:             c.Bool("repo.auto_tag"),
+			AutoTagBranch:       c.Bool("repo.auto_tag_branch"),
+			PullRequestTag:      c.String("repo.pull_request_tag"),
+			DeploymentTag:       c.String("repo.deployment_tag"),
+			ScheduleTag:         c.String("repo.schedule_tag"),
+			CalverLayout:        c.String("repo.calver_layout"),
+			CalverTimezone:      c.String("repo.calver_timezone"),
+			CalverBuildNumber:   c.Bool("repo.calver_build_number"),
+			Cache:               c.Bool("repo.cache"),
+			CacheName:           c.String("repo.cache_name"),
+			Compression:         c.String("repo.compression"),
+			CompressionLevel:    c.Int("repo.compression_level"),
+			CompressedCaching:   c.Bool("repo.compressed_caching"),
+			Name:                c.String("repo.name"),
+			Tags:                c.StringSlice("repo.tags"),
+			TagsFile:            c.String("repo.tags_file"),
+			TopicsFilter:        c.String("repo.topics_filter"),
+			SanitizeTags:        c.Bool("repo.sanitize_tags"),
+			ImmutableTags:       c.String("repo.immutable_tags"),
+			ImmutableTagsAction: c.String("repo.immutable_tags_action"),
+			TagPrefix:           c.String("repo.tag_prefix"),
+			TagSuffix:           c.String("repo.tag_suffix"),
+			SemverTags:          c.Bool("repo.semver_tags"),
+			SemverLatest:        c.Bool("repo.semver_latest"),
 			Label: &Label{
 				AuthorEmail: c.String("label.author_email"),
 				Commit:      c.String("label.commit"),
@@ -625,13 +1096,53 @@ func run(ctx context.Context, c *cli.Command) error {
 		},
 	}
 
+	// check if a file with build args is provided, which is read
+	// first so any masked build args from the file are captured
+	if len(p.Image.ArgsFile) > 0 {
+		err := p.Image.ReadArgsFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	// capture the secrets to mask in output
+	secrets, err := p.Secrets()
+	if err != nil {
+		return err
+	}
+
+	secretMasker.Add(secrets...)
+
+	// check if a file with repo tags is provided
+	if len(p.Repo.TagsFile) > 0 {
+		err = p.Repo.ReadTagsFile()
+		if err != nil {
+			return err
+		}
+	}
+
 	// check if repo auto tagging is enabled
 	if p.Repo.AutoTag {
-		p.Repo.ConfigureAutoTagBuildTags(p.Build)
+		err = p.Repo.ConfigureAutoTagBuildTags(p.Build)
+		if err != nil {
+			return err
+		}
+	}
+
+	// render any tag and label templates
+	err = p.Render()
+	if err != nil {
+		return err
+	}
+
+	// sanitize the tags if enabled
+	err = p.Sanitize()
+	if err != nil {
+		return err
 	}
 
 	// validate the plugin
-	err := p.Validate()
+	err = p.Validate()
 	if err != nil {
 		return err
 	}
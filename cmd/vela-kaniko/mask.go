@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+const (
+	// maskValue defines the value secrets are replaced with.
+	maskValue = "***"
+
+	// maskMinLength defines the shortest value that is masked, since
+	// masking shorter values would redact most of the output.
+	maskMinLength = 4
+
+	// secretsDir defines the directory Vela mounts secrets in.
+	secretsDir = "/vela/secrets"
+)
+
+// parameterDir defines the directory in which secrets are
+// read as parameters for the plugin, such as log_level.
+var parameterDir = filepath.Join(secretsDir, "kaniko")
+
+// secretParameters defines the parameters read from the parameter
+// directory that are masked, since the others are not secret values.
+var secretParameters = []string{
+	"password",
+	"identity_token",
+	"registry_token",
+	"mirror_password",
+	"mirror_token",
+}
+
+// secretMasker is the masker used for all output from the plugin.
+var secretMasker = new(masker)
+
+type (
+	// masker redacts secret values from output.
+	masker struct {
+		mu sync.RWMutex
+		// secret values sorted from longest to shortest
+		secrets []string
+	}
+
+	// maskWriter represents an io.Writer that masks secrets in each line written.
+	maskWriter struct {
+		masker *masker
+		writer io.Writer
+		// partial line waiting for a newline
+		buf []byte
+	}
+
+	// maskFormatter represents a logrus.Formatter that masks secrets in each entry.
+	maskFormatter struct {
+		logrus.Formatter
+		masker *masker
+	}
+)
+
+// Add adds the values to the secrets that are masked.
+func (m *masker) Add(values ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if len(value) == 0 || slices.Contains(m.secrets, value) {
+			continue
+		}
+
+		// check if the value is too short to be masked
+		if len(value) < maskMinLength {
+			logrus.Warnf("unable to mask secret shorter than %d characters", maskMinLength)
+
+			continue
+		}
+
+		m.secrets = append(m.secrets, value)
+	}
+
+	// mask longer values first, so a secret containing
+	// another secret is not partially revealed
+	slices.SortStableFunc(m.secrets, func(a, b string) int {
+		return len(b) - len(a)
+	})
+}
+
+// Mask replaces any secrets in the value.
+func (m *masker) Mask(value string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, secret := range m.secrets {
+		value = strings.ReplaceAll(value, secret, maskValue)
+	}
+
+	return value
+}
+
+// Writer creates an io.Writer that masks secrets before writing to w.
+func (m *masker) Writer(w io.Writer) *maskWriter {
+	return &maskWriter{
+		masker: m,
+		writer: w,
+	}
+}
+
+// Write implements the io.Writer interface.
+//
+// Output is buffered until a newline, so a secret split
+// across multiple writes is still masked.
+func (w *maskWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	// write each complete line
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+
+	_, err := io.WriteString(w.writer, w.masker.Mask(string(w.buf[:i+1])))
+
+	w.buf = w.buf[i+1:]
+
+	return len(p), err
+}
+
+// Flush writes any partial line remaining in the buffer.
+func (w *maskWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(w.writer, w.masker.Mask(string(w.buf)))
+
+	w.buf = nil
+
+	return err
+}
+
+// Format implements the logrus.Formatter interface.
+func (f *maskFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	out, err := f.Formatter.Format(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(f.masker.Mask(string(out))), nil
+}
+
+// secretFiles returns the values of the files in the secrets directory.
+//
+// Files in the parameter directory are skipped, unless they contain
+// credentials, since they provide the configuration for the plugin.
+func secretFiles() ([]string, error) {
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	values := []string{}
+
+	err := a.Walk(secretsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// an absent secrets directory has no secrets
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		// check if the file provides a parameter for the plugin
+		if filepath.Dir(path) == parameterDir && !slices.Contains(secretParameters, filepath.Base(path)) {
+			return nil
+		}
+
+		data, err := a.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		values = append(values, string(data))
+
+		return nil
+	})
+
+	return values, err
+}
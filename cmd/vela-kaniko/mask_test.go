@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+func TestDocker_Masker_Mask(t *testing.T) {
+	// setup types
+	m := new(masker)
+	m.Add("superSecretPassword", "Secret", "abc", "", "  padded\n")
+
+	// setup tests
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{ // password
+			value: "--build-arg=PASSWORD=superSecretPassword",
+			want:  "--build-arg=PASSWORD=***",
+		},
+		{ // secret within another secret
+			value: "Secret and superSecretPassword",
+			want:  "*** and ***",
+		},
+		{ // short value
+			value: "abc",
+			want:  "abc",
+		},
+		{ // trimmed value
+			value: "--build-arg=FOO=padded",
+			want:  "--build-arg=FOO=***",
+		},
+		{ // no secret
+			value: "--context=.",
+			want:  "--context=.",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := m.Mask(test.value)
+
+		if got != test.want {
+			t.Errorf("Mask is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestDocker_Masker_Add_Short(t *testing.T) {
+	// capture the log output
+	buf := new(bytes.Buffer)
+
+	logrus.SetOutput(buf)
+	t.Cleanup(func() { logrus.SetOutput(os.Stderr) })
+
+	// setup types
+	m := new(masker)
+	m.Add("abc", "")
+
+	if len(m.secrets) != 0 {
+		t.Errorf("Add secrets is %v, want none", m.secrets)
+	}
+
+	// verify a warning is logged for the short secret only, without its value
+	got := buf.String()
+
+	if strings.Count(got, "unable to mask secret") != 1 || strings.Contains(got, "abc") {
+		t.Errorf("Add output is %q, want one warning", got)
+	}
+}
+
+func TestDocker_Masker_Writer(t *testing.T) {
+	// setup types
+	m := new(masker)
+	m.Add("superSecretPassword")
+
+	out := new(bytes.Buffer)
+	w := m.Writer(out)
+
+	// write the secret across multiple writes
+	for _, s := range []string{"pushing with super", "SecretPassword\nINFO", "[0001] superSecret", "Password"} {
+		_, err := w.Write([]byte(s))
+		if err != nil {
+			t.Errorf("Write returned err: %v", err)
+		}
+	}
+
+	if out.String() != "pushing with ***\n" {
+		t.Errorf("Write is %q, want %q", out.String(), "pushing with ***\n")
+	}
+
+	err := w.Flush()
+	if err != nil {
+		t.Errorf("Flush returned err: %v", err)
+	}
+
+	want := "pushing with ***\nINFO[0001] ***"
+
+	if out.String() != want {
+		t.Errorf("Flush is %q, want %q", out.String(), want)
+	}
+}
+
+func TestDocker_Masker_Formatter(t *testing.T) {
+	// setup types
+	m := new(masker)
+	m.Add("superSecretPassword")
+
+	f := &maskFormatter{
+		Formatter: &logrus.TextFormatter{DisableTimestamp: true},
+		masker:    m,
+	}
+
+	got, err := f.Format(&logrus.Entry{
+		Level:   logrus.TraceLevel,
+		Message: "executing cmd /kaniko/executor --build-arg=PASSWORD=superSecretPassword",
+	})
+	if err != nil {
+		t.Errorf("Format returned err: %v", err)
+	}
+
+	want := "level=trace msg=\"executing cmd /kaniko/executor --build-arg=PASSWORD=***\"\n"
+
+	if string(got) != want {
+		t.Errorf("Format is %q, want %q", got, want)
+	}
+}
+
+func TestDocker_Plugin_Secrets(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	files := map[string]string{
+		"/vela/secrets/npm_token":            "npmSecretToken\n",
+		"/vela/secrets/kaniko/mirror_token":  "mirrorSecretToken",
+		"/vela/secrets/kaniko/log_level":     "info",
+		"/vela/secrets/kaniko/registry":      "index.docker.io",
+		"/vela/secrets/kaniko/sanitize_tags": "true",
+	}
+
+	for path, value := range files {
+		err := a.WriteFile(path, []byte(value), 0600)
+		if err != nil {
+			t.Errorf("unable to write secret file: %v", err)
+		}
+	}
+
+	// setup types
+	p := &Plugin{
+		Image: &Image{
+			Args:     []string{"FOO=bar", "NPM_TOKEN=npmSecretToken", "GITHUB_TOKEN=githubSecretToken"},
+			MaskArgs: []string{"GITHUB_TOKEN"},
+		},
+		Registry: &Registry{
+			Name:     "index.docker.io",
+			Username: "octocat",
+			Password: "superSecretPassword",
+			Credentials: []*Credential{
+				{
+					Registry:      "ghcr.io",
+					IdentityToken: "superSecretToken",
+				},
+			},
+			DockerConfig: `{"auths": {"quay.io": {"auth": "b2N0b2NhdDpxdWF5U2VjcmV0UGFzc3dvcmQ="}}}`,
+		},
+	}
+
+	got, err := p.Secrets()
+	if err != nil {
+		t.Errorf("Secrets returned err: %v", err)
+	}
+
+	m := new(masker)
+	m.Add(got...)
+
+	// setup tests
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "npmSecretToken", want: "***"},
+		{value: "githubSecretToken", want: "***"},
+		{value: "superSecretPassword", want: "***"},
+		{value: "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk", want: "***"},
+		{value: "superSecretToken", want: "***"},
+		{value: "quaySecretPassword", want: "***"},
+		{value: "mirrorSecretToken", want: "***"},
+		{value: "FOO=bar", want: "FOO=bar"},
+		{value: "octocat", want: "octocat"},
+		{value: "info", want: "info"},
+		{value: "index.docker.io", want: "index.docker.io"},
+		{value: "true", want: "true"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := m.Mask(test.value)
+
+		if got != test.want {
+			t.Errorf("Mask is %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestDocker_Plugin_Secrets_NoSecretsDir(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	p := &Plugin{
+		Image:    &Image{},
+		Registry: &Registry{Name: "index.docker.io"},
+	}
+
+	got, err := p.Secrets()
+	if err != nil {
+		t.Errorf("Secrets returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, []string{}) {
+		t.Errorf("Secrets is %v, want %v", got, []string{})
+	}
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// imagePlatform represents the platform an image is built for.
+	//
+	// https://github.com/opencontainers/image-spec/blob/main/image-index.md#image-index-property-descriptions
+	imagePlatform struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+		Variant      string `json:"variant,omitempty"`
+	}
+
+	// descriptor represents a reference to a manifest published to a registry.
+	//
+	// https://github.com/opencontainers/image-spec/blob/main/descriptor.md
+	descriptor struct {
+		MediaType string         `json:"mediaType"`
+		Digest    string         `json:"digest"`
+		Size      int64          `json:"size"`
+		Platform  *imagePlatform `json:"platform,omitempty"`
+	}
+
+	// imageIndex represents an OCI image index or Docker manifest list.
+	//
+	// https://github.com/opencontainers/image-spec/blob/main/image-index.md
+	imageIndex struct {
+		SchemaVersion int           `json:"schemaVersion"`
+		MediaType     string        `json:"mediaType"`
+		Manifests     []*descriptor `json:"manifests"`
+	}
+
+	// platformImage represents the image published to a repository for a platform.
+	platformImage struct {
+		// platform the image is built for, such as linux/arm64
+		Platform string
+		// tag the image is published with
+		Tag string
+	}
+)
+
+// parsePlatform parses a platform in the form os/arch[/variant].
+func parsePlatform(platform string) (*imagePlatform, error) {
+	parts := strings.Split(platform, "/")
+
+	// verify the os and architecture are provided
+	if len(parts) < 2 || len(parts) > 3 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return nil, fmt.Errorf("platform %s is not in the format os/arch[/variant]", platform)
+	}
+
+	p := &imagePlatform{
+		OS:           parts[0],
+		Architecture: parts[1],
+	}
+
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+
+	return p, nil
+}
+
+// platformTag converts a platform, such as linux/arm64/v8, into
+// the suffix of a docker tag, such as linux-arm64-v8.
+func platformTag(platform string) string {
+	return strings.ReplaceAll(platform, "/", "-")
+}
+
+// forPlatform returns a copy of the plugin configured to build
+// and publish the image for a single platform.
+//
+// The image is published with the destination tags suffixed by the
+// platform, and saved to a tarball suffixed by the platform if requested.
+func (p *Plugin) forPlatform(platform string) *Plugin {
+	build := *p.Build
+	image := *p.Image
+	repo := p.Repo.clone()
+
+	// clean up the filesystem after the build, so the next build starts fresh
+	build.Cleanup = true
+
+	// check if the image is saved as a tarball
+	if len(build.TarPath) > 0 {
+		ext := filepath.Ext(build.TarPath)
+
+		build.TarPath = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(build.TarPath, ext), platformTag(platform), ext)
+	}
+
+	image.CustomPlatform = platform
+	image.Platforms = nil
+
+	repo.TagSuffix = fmt.Sprintf("%s-%s", p.Repo.TagSuffix, platformTag(platform))
+
+	return &Plugin{
+		Build:    &build,
+		Image:    &image,
+		Registry: p.Registry,
+		Repo:     repo,
+	}
+}
+
+// buildPlatforms runs the kaniko build for each platform one after another
+// and publishes an image index referencing them under the destination tags.
+func (p *Plugin) buildPlatforms(ctx context.Context) error {
+	images := []*platformImage{}
+
+	for i, platform := range p.Image.Platforms {
+		pp := p.forPlatform(platform)
+
+		logrus.Infof("building image for platform %s (%d of %d)", platform, i+1, len(p.Image.Platforms))
+
+		err := execCmd(pp.Command(ctx))
+		if err != nil {
+			return fmt.Errorf("unable to build image for platform %s: %w", platform, err)
+		}
+
+		images = append(images, &platformImage{
+			Platform: platform,
+			Tag:      pp.Repo.DestinationTags()[0],
+		})
+	}
+
+	// check if the images were published
+	if p.Registry.DryRun {
+		logrus.Info("skipping image index since dry run is enabled")
+
+		return nil
+	}
+
+	return p.Registry.PushIndex(ctx, p.Repo.Name, images, p.Repo.DestinationTags())
+}
@@ -0,0 +1,322 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// testRegistry represents an in-process registry which stores manifests in memory.
+type testRegistry struct {
+	mu sync.Mutex
+	// manifests for each repo and reference
+	manifests map[string][]byte
+	// media types for each repo and reference
+	mediaTypes map[string]string
+}
+
+// newTestRegistry creates an in-process registry serving manifests over plain HTTP.
+func newTestRegistry(t *testing.T) (*testRegistry, string) {
+	tr := &testRegistry{
+		manifests:  make(map[string][]byte),
+		mediaTypes: make(map[string]string),
+	}
+
+	s := httptest.NewServer(tr)
+	t.Cleanup(s.Close)
+
+	return tr, strings.TrimPrefix(s.URL, "http://")
+}
+
+// put stores the manifest under the reference and its digest, returning the digest.
+func (tr *testRegistry) put(repo, ref, mediaType string, body []byte) string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	sum := sha256.Sum256(body)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	for _, key := range []string{repo + ":" + ref, repo + "@" + digest} {
+		tr.manifests[key] = body
+		tr.mediaTypes[key] = mediaType
+	}
+
+	return digest
+}
+
+// get returns the manifest and media type stored under the reference.
+func (tr *testRegistry) get(repo, ref string) ([]byte, string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	key := repo + ":" + ref
+	if strings.HasPrefix(ref, "sha256:") {
+		key = repo + "@" + ref
+	}
+
+	return tr.manifests[key], tr.mediaTypes[key]
+}
+
+// ServeHTTP serves the manifest endpoints of the Docker Registry HTTP API V2.
+func (tr *testRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	repo, ref, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		body, mediaType := tr.get(repo, ref)
+		if body == nil {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(http.StatusOK)
+
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		tr.put(repo, ref, r.Header.Get("Content-Type"), body)
+
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestDocker_parsePlatform(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		platform string
+		want     *imagePlatform
+		failure  bool
+	}{
+		{platform: "linux/amd64", want: &imagePlatform{OS: "linux", Architecture: "amd64"}},
+		{platform: "linux/arm64/v8", want: &imagePlatform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		{platform: "linux", failure: true},
+		{platform: "linux/", failure: true},
+		{platform: "linux/arm/v7/extra", failure: true},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got, err := parsePlatform(test.platform)
+
+		if test.failure {
+			if err == nil {
+				t.Errorf("parsePlatform for %s should have returned err", test.platform)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parsePlatform for %s returned err: %v", test.platform, err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parsePlatform for %s is %v, want %v", test.platform, got, test.want)
+		}
+	}
+}
+
+func TestDocker_Plugin_forPlatform(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			TarPath: "/vela/src/image.tar",
+		},
+		Image: &Image{
+			Platforms: []string{"linux/amd64", "linux/arm64/v8"},
+		},
+		Registry: &Registry{},
+		Repo: &Repo{
+			Name:      "index.docker.io/octocat/hello-world",
+			Tags:      []string{"latest", "1.2.3"},
+			TagSuffix: "-alpine",
+			Labels:    []string{"foo=bar"},
+			Label:     testLabel(),
+		},
+	}
+
+	// run test
+	got := p.forPlatform("linux/arm64/v8")
+
+	if got.Image.CustomPlatform != "linux/arm64/v8" || len(got.Image.Platforms) != 0 {
+		t.Errorf("forPlatform image is %+v", got.Image)
+	}
+
+	if !got.Build.Cleanup || got.Build.TarPath != "/vela/src/image-linux-arm64-v8.tar" {
+		t.Errorf("forPlatform build is %+v", got.Build)
+	}
+
+	want := []string{"latest-alpine-linux-arm64-v8", "1.2.3-alpine-linux-arm64-v8"}
+
+	if !reflect.DeepEqual(got.Repo.DestinationTags(), want) {
+		t.Errorf("forPlatform tags is %v, want %v", got.Repo.DestinationTags(), want)
+	}
+
+	// verify the plugin is not modified
+	if p.Repo.TagSuffix != "-alpine" || p.Build.TarPath != "/vela/src/image.tar" {
+		t.Errorf("forPlatform modified plugin %+v %+v", p.Repo, p.Build)
+	}
+
+	got.Repo.Labels = append(got.Repo.Labels, "baz=qux")
+
+	if !reflect.DeepEqual(p.Repo.Labels, []string{"foo=bar"}) {
+		t.Errorf("Repo labels is %v, want %v", p.Repo.Labels, []string{"foo=bar"})
+	}
+
+	if got.Repo.Label == p.Repo.Label || !reflect.DeepEqual(got.Repo.Label, p.Repo.Label) {
+		t.Errorf("forPlatform label is %v, want a copy of %v", got.Repo.Label, p.Repo.Label)
+	}
+}
+
+func TestDocker_Plugin_Exec_Platforms(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// restore the kaniko executor after the test
+	bin := kanikoBin
+
+	t.Cleanup(func() { kanikoBin = bin })
+
+	kanikoBin = "true"
+
+	// setup registry with the images published by kaniko for each platform
+	tr, host := newTestRegistry(t)
+
+	amd64 := tr.put("octocat/hello-world", "latest-linux-amd64", dockerManifestMediaType, []byte(`{"schemaVersion":2,"architecture":"amd64"}`))
+	arm64 := tr.put("octocat/hello-world", "latest-linux-arm64", dockerManifestMediaType, []byte(`{"schemaVersion":2,"architecture":"arm64"}`))
+
+	p := &Plugin{
+		Build: &Build{
+			Event: "push",
+			Sha:   "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+		},
+		Image: &Image{
+			Context:    ".",
+			Dockerfile: "Dockerfile",
+			Platforms:  []string{"linux/amd64", "linux/arm64"},
+		},
+		Registry: &Registry{
+			Name:               host,
+			InsecureRegistries: []string{host},
+		},
+		Repo: &Repo{
+			Name:  host + "/octocat/hello-world",
+			Tags:  []string{"latest", "1.2.3"},
+			Label: testLabel(),
+		},
+	}
+
+	// run test
+	err := p.Exec(t.Context())
+	if err != nil {
+		t.Errorf("Exec returned err: %v", err)
+	}
+
+	for _, tag := range []string{"latest", "1.2.3"} {
+		body, mediaType := tr.get("octocat/hello-world", tag)
+
+		if mediaType != dockerListMediaType {
+			t.Errorf("index %s media type is %s, want %s", tag, mediaType, dockerListMediaType)
+		}
+
+		index := new(imageIndex)
+
+		err = json.Unmarshal(body, index)
+		if err != nil {
+			t.Errorf("Unmarshal for %s returned err: %v", tag, err)
+		}
+
+		want := []*descriptor{
+			{
+				MediaType: dockerManifestMediaType,
+				Digest:    amd64,
+				Size:      int64(len(`{"schemaVersion":2,"architecture":"amd64"}`)),
+				Platform:  &imagePlatform{OS: "linux", Architecture: "amd64"},
+			},
+			{
+				MediaType: dockerManifestMediaType,
+				Digest:    arm64,
+				Size:      int64(len(`{"schemaVersion":2,"architecture":"arm64"}`)),
+				Platform:  &imagePlatform{OS: "linux", Architecture: "arm64"},
+			},
+		}
+
+		if !reflect.DeepEqual(index.Manifests, want) {
+			t.Errorf("index %s manifests is %+v, want %+v", tag, index.Manifests, want)
+		}
+	}
+}
+
+func TestDocker_Registry_PushIndex_OCI(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup registry
+	tr, host := newTestRegistry(t)
+
+	tr.put("octocat/hello-world", "latest-linux-amd64", ociManifestMediaType, []byte(`{"schemaVersion":2}`))
+
+	r := &Registry{
+		Name:               host,
+		InsecureRegistries: []string{host},
+	}
+
+	images := []*platformImage{
+		{Platform: "linux/amd64", Tag: "latest-linux-amd64"},
+	}
+
+	// run test
+	err := r.PushIndex(t.Context(), host+"/octocat/hello-world", images, []string{"latest"})
+	if err != nil {
+		t.Errorf("PushIndex returned err: %v", err)
+	}
+
+	_, mediaType := tr.get("octocat/hello-world", "latest")
+	if mediaType != ociIndexMediaType {
+		t.Errorf("PushIndex media type is %s, want %s", mediaType, ociIndexMediaType)
+	}
+
+	// verify a missing image returns an error
+	images = append(images, &platformImage{Platform: "linux/arm64", Tag: "latest-linux-arm64"})
+
+	err = r.PushIndex(t.Context(), host+"/octocat/hello-world", images, []string{"latest"})
+	if err == nil {
+		t.Errorf("PushIndex should have returned err")
+	}
+
+	// verify a manifest exceeding the limit returns an error
+	tr.put("octocat/hello-world", "latest-linux-arm64", ociManifestMediaType, make([]byte, maxManifestSize+1))
+
+	err = r.PushIndex(t.Context(), host+"/octocat/hello-world", images, []string{"latest"})
+	if err == nil {
+		t.Errorf("PushIndex should have returned err")
+	}
+}
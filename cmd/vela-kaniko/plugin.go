@@ -4,11 +4,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 )
@@ -21,6 +26,21 @@ var (
 	//  - https://docs.docker.com/engine/reference/commandline/tag/#extended-description
 	//  - https://github.com/distribution/distribution/blob/01f589cf8726565aa3c5c053be12873bafedbedc/reference/regexp.go#L41
 	tagRegexp = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+	// regular expression to match characters not allowed in docker tags
+	invalidTagRegexp = regexp.MustCompile(`[^\w.-]+`)
+
+	// regular expressions to validate the prefix and suffix added to docker tags
+	tagPrefixRegexp = regexp.MustCompile(`^([\w][\w.-]*)?$`)
+	tagSuffixRegexp = regexp.MustCompile(`^[\w.-]*$`)
+)
+
+const (
+	// maxTagLength defines the maximum length of a docker tag.
+	maxTagLength = 128
+
+	// tagHashLength defines the length of the hash appended to truncated tags.
+	tagHashLength = 8
 )
 
 // errTagValidation defines the error message
@@ -64,6 +84,21 @@ func (p *Plugin) Command(ctx context.Context) *exec.Cmd {
 		flags = append(flags, "--single-snapshot")
 	}
 
+	// check if the image should be built reproducibly
+	if p.Build.Reproducible {
+		flags = append(flags, "--reproducible")
+	}
+
+	// check if the filesystem should be cleaned up after the build
+	if p.Build.Cleanup {
+		flags = append(flags, "--cleanup")
+	}
+
+	// check if the digest of the image should be written to a file
+	if len(p.Build.DigestFile) > 0 {
+		flags = append(flags, fmt.Sprintf("--digest-file=%s", p.Build.DigestFile))
+	}
+
 	flags = append(flags, fmt.Sprintf("--ignore-var-run=%s", strconv.FormatBool(p.Build.IgnoreVarRun)))
 
 	// add paths to be ignored if provided
@@ -78,8 +113,8 @@ func (p *Plugin) Command(ctx context.Context) *exec.Cmd {
 		flags = append(flags, "--log-timestamp")
 	}
 
-	// iterate through all image build args
-	for _, arg := range p.Image.Args {
+	// iterate through all image build args, sorted so the command is stable
+	for _, arg := range sortPairs(p.Image.Args) {
 		// add flag for build args from provided image build arg
 		flags = append(flags, fmt.Sprintf("--build-arg=%s", arg))
 	}
@@ -89,14 +124,8 @@ func (p *Plugin) Command(ctx context.Context) *exec.Cmd {
 		// add flag for caching from provided repo cache
 		flags = append(flags, "--cache")
 
-		// check if repo cache name is provided
-		if len(p.Repo.CacheName) > 0 {
-			// add flag for cache repo from provided repo cache name
-			flags = append(flags, fmt.Sprintf("--cache-repo=%s", p.Repo.CacheName))
-		} else {
-			// add flag for cache repo from provided repo name
-			flags = append(flags, fmt.Sprintf("--cache-repo=%s", p.Repo.Name))
-		}
+		// add flag for cache repo from provided repo cache name or repo name
+		flags = append(flags, fmt.Sprintf("--cache-repo=%s", p.Repo.CacheRepo()))
 	}
 
 	// check if compression is provided
@@ -117,8 +146,8 @@ func (p *Plugin) Command(ctx context.Context) *exec.Cmd {
 	// add flag for context from provided image context
 	flags = append(flags, fmt.Sprintf("--context=%s", p.Image.Context))
 
-	// iterate through all repo tags
-	for _, tag := range p.Repo.Tags {
+	// iterate through all repo tags with any prefix and suffix, sorted so the command is stable
+	for _, tag := range slices.Sorted(slices.Values(p.Repo.DestinationTags())) {
 		// add flag for tag from provided repo tag
 		flags = append(flags, fmt.Sprintf("--destination=%s:%s", p.Repo.Name, tag))
 	}
@@ -132,9 +161,9 @@ func (p *Plugin) Command(ctx context.Context) *exec.Cmd {
 		flags = append(flags, "--no-push")
 	}
 
-	// check if the docker registry mirror is set
-	if len(p.Registry.Mirror) != 0 {
-		flags = append(flags, fmt.Sprintf("--registry-mirror=%s", p.Registry.Mirror))
+	// iterate through all docker registry mirrors
+	for _, mirror := range p.Registry.Mirrors {
+		flags = append(flags, fmt.Sprintf("--registry-mirror=%s", mirror))
 	}
 
 	// check if retry is set
@@ -180,11 +209,12 @@ func (p *Plugin) Command(ctx context.Context) *exec.Cmd {
 	// add flag for logging verbosity
 	flags = append(flags, fmt.Sprintf("--verbosity=%s", logrus.GetLevel()))
 
-	// add predefined labels to user provided labels
-	p.Repo.Labels = append(p.Repo.Labels, p.Repo.AddLabels()...)
+	// add predefined labels to user provided labels, without modifying
+	// the repo labels so the command can be created more than once
+	labels := append(slices.Clone(p.Repo.Labels), p.Repo.AddLabels()...)
 
-	// iterate through all repo labels
-	for _, label := range p.Repo.Labels {
+	// iterate through all repo labels, sorted so the command is stable
+	for _, label := range sortPairs(labels) {
 		// add flag for tag from provided repo tag
 		flags = append(flags, fmt.Sprintf("--label=%s", label))
 	}
@@ -193,27 +223,376 @@ func (p *Plugin) Command(ctx context.Context) *exec.Cmd {
 }
 
 // Exec formats and runs the commands for building and publishing a Docker image.
-func (p *Plugin) Exec(ctx context.Context) error {
+func (p *Plugin) Exec(ctx context.Context) (err error) {
 	logrus.Debug("running plugin with provided configuration")
 
 	// create registry file for authentication
-	err := p.Registry.Write()
+	err = p.Registry.Write()
 	if err != nil {
 		return err
 	}
 
+	// remove registry file once finished, whether or not the build succeeded
+	defer func() {
+		err = errors.Join(err, p.Registry.Cleanup())
+	}()
+
 	// output the kaniko version for troubleshooting
 	err = execCmd(versionCmd(ctx))
 	if err != nil {
 		return err
 	}
 
+	// check if several images are built in the step
+	if len(p.Image.Builds) > 0 {
+		return p.execSpecs(ctx)
+	}
+
+	return p.build(ctx)
+}
+
+// build runs the checks for publishing the image and
+// the kaniko command for building and publishing it.
+func (p *Plugin) build(ctx context.Context) error {
+	// check if registry authentication should be verified before building
+	if p.Registry.Preflight && !p.Registry.DryRun {
+		err := p.Registry.CheckAuth(ctx, p.Repo.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if push permission should be verified before building
+	if p.Registry.PreflightPush {
+		err := p.checkPush(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if the latest tag should be verified before publishing
+	if !p.Registry.DryRun {
+		p.checkLatest(ctx)
+	}
+
+	// check if immutable tags should be verified before publishing
+	if len(p.Repo.ImmutableTags) > 0 && !p.Registry.DryRun {
+		err := p.checkImmutable(ctx)
+		if err != nil {
+			return err
+		}
+
+		// check if all tags were skipped
+		if len(p.Repo.Tags) == 0 {
+			logrus.Info("all tags are immutable and already published, skipping build")
+
+			return nil
+		}
+	}
+
+	// check if the image is built for several platforms
+	if len(p.Image.Platforms) > 0 {
+		return p.buildPlatforms(ctx)
+	}
+
 	// run kaniko command from plugin configuration
-	err = execCmd(p.Command(ctx))
+	return execCmd(p.Command(ctx))
+}
+
+// checkPush verifies push permission for the repositories the image and cache are published to.
+func (p *Plugin) checkPush(ctx context.Context) error {
+	// check if the image is published to the repo
+	if !p.Registry.DryRun {
+		err := p.Registry.CheckPush(ctx, p.Repo.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if the cache repo was not verified above, since
+	// kaniko publishes cached layers even for a dry run
+	if p.Repo.Cache && (p.Registry.DryRun || p.Repo.CacheRepo() != p.Repo.Name) {
+		err := p.Registry.CheckPush(ctx, p.Repo.CacheRepo())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkLatest removes the latest tag from the repo tags when a newer
+// semantic version than the one being built is already published.
+//
+// Only the published tags with the same tag prefix and suffix are compared,
+// and the latest tag is kept if the published tags can not be listed.
+func (p *Plugin) checkLatest(ctx context.Context) {
+	latest := p.Repo.TagPrefix + "latest" + p.Repo.TagSuffix
+
+	// check if the latest tag is added for a semantic version
+	if p.Repo.version == nil || !slices.Contains(p.Repo.DestinationTags(), latest) {
+		return
+	}
+
+	tags, err := p.Registry.ListTags(ctx, p.Repo.Name)
+	if err != nil {
+		logrus.Warnf("unable to list tags for %s to verify %s tag: %v", p.Repo.Name, latest, err)
+
+		return
+	}
+
+	for _, tag := range tags {
+		// check if the tag is published with the same prefix and suffix
+		version, ok := strings.CutPrefix(tag, p.Repo.TagPrefix)
+		if !ok {
+			continue
+		}
+
+		version, ok = strings.CutSuffix(version, p.Repo.TagSuffix)
+		if !ok {
+			continue
+		}
+
+		v, err := semver.StrictNewVersion(strings.TrimPrefix(version, "v"))
+		if err != nil || len(v.Prerelease()) > 0 {
+			continue
+		}
+
+		// check if the published version is newer than the version being built
+		if v.GreaterThan(p.Repo.version) {
+			logrus.Warnf("skipping %s tag since newer version %s is published to %s", latest, tag, p.Repo.Name)
+
+			p.Repo.removeTag(latest)
+
+			return
+		}
+	}
+}
+
+// checkImmutable verifies the destination tags matching the immutable tags
+// are not already published with a different image, and either fails or
+// skips those that are.
+//
+// Since the digest of the image is not known until after it is built, the
+// image is first built without publishing it when any immutable tag is
+// already published and the image is built reproducibly. A tag published
+// with the same image is removed from the tags, so it is never published
+// again by a build that may not produce the same image. Otherwise, an
+// immutable tag that is already published is always treated as different.
+//
+// When building for multiple platforms, the image for each platform is
+// compared with the tag suffixed by the platform, and an image index
+// already published is only the same when every platform image matches.
+func (p *Plugin) checkImmutable(ctx context.Context) error {
+	// we already confirmed validity of regex expression in
+	// .Validate, so we skip the error check here
+	re, _ := regexp.Compile(p.Repo.ImmutableTags)
+
+	// capture the image built for each suffix of the destination tags
+	suffixes := []string{""}
+	images := map[string]*Plugin{"": p}
+
+	if len(p.Image.Platforms) > 0 {
+		suffixes = []string{}
+		images = make(map[string]*Plugin)
+
+		for _, platform := range p.Image.Platforms {
+			suffix := "-" + platformTag(platform)
+
+			suffixes = append(suffixes, suffix)
+			images[suffix] = p.forPlatform(platform)
+		}
+	}
+
+	// capture the digest of each immutable destination already published
+	published := make(map[string]string)
+
+	for _, tag := range p.Repo.DestinationTags() {
+		// check if the tag is immutable
+		if !re.MatchString(tag) {
+			continue
+		}
+
+		for _, destination := range immutableDestinations(tag, suffixes) {
+			digest, err := p.Registry.TagDigest(ctx, p.Repo.Name, destination)
+			if err != nil {
+				return fmt.Errorf("unable to verify immutable tag %s: %w", destination, err)
+			}
+
+			if len(digest) > 0 {
+				published[destination] = digest
+			}
+		}
+	}
+
+	// check if any immutable tag is already published
+	if len(published) == 0 {
+		return nil
+	}
+
+	// capture the digest of the image built for each suffix
+	built := make(map[string]string)
+
+	// check if the image is built reproducibly, since the
+	// digest of the image otherwise changes on each build
+	if p.Build.Reproducible {
+		for _, suffix := range suffixes {
+			digest, err := images[suffix].imageDigest(ctx)
+			if err != nil {
+				return err
+			}
+
+			built[suffix] = digest
+		}
+	}
+
+	for _, tag := range p.Repo.DestinationTags() {
+		same, err := verifyImmutable(tag, suffixes, published, built)
+
+		// check if the tag is already published with the same image
+		if same {
+			logrus.Infof("skipping immutable tag %s already published to %s with the same image", tag, p.Repo.Name)
+
+			p.Repo.removeTag(tag)
+
+			continue
+		}
+
+		if err == nil {
+			continue
+		}
+
+		// check if the tag should be skipped rather than failing the build
+		if p.Repo.ImmutableTagsAction == immutableSkip {
+			logrus.Warnf("skipping immutable tag %s already published to %s with a different image", tag, p.Repo.Name)
+
+			p.Repo.removeTag(tag)
+
+			continue
+		}
+
+		return fmt.Errorf("unable to publish to %s: %w", p.Repo.Name, err)
+	}
+
+	return nil
+}
+
+// immutableDestinations returns the destinations published for the tag,
+// which are the tag and the tag suffixed by each platform.
+func immutableDestinations(tag string, suffixes []string) []string {
+	destinations := []string{tag}
+
+	for _, suffix := range suffixes {
+		destinations = append(destinations, tag+suffix)
+	}
+
+	return slices.Compact(destinations)
+}
+
+// verifyImmutable verifies the images already published for the destination
+// tag, and the tag suffixed by each platform, match the images built. It
+// returns true when every destination is published with the same image.
+func verifyImmutable(tag string, suffixes []string, published, built map[string]string) (bool, error) {
+	destinations := immutableDestinations(tag, suffixes)
+
+	// check if any destination is published for the tag
+	if !slices.ContainsFunc(destinations, func(d string) bool { return len(published[d]) > 0 }) {
+		return false, nil
+	}
+
+	// the tag must be published completely, since a destination
+	// published again may not be the same image as the others
+	for _, destination := range destinations {
+		if len(published[destination]) == 0 {
+			return false, fmt.Errorf("immutable tag %s already partially published without %s", tag, destination)
+		}
+	}
+
+	for _, suffix := range suffixes {
+		destination := tag + suffix
+
+		// check if the image was built to compare with
+		if len(built[suffix]) == 0 {
+			return false, fmt.Errorf("immutable tag %s already published with digest %s", destination, published[destination])
+		}
+
+		// check if the destination is published with a different image
+		if published[destination] != built[suffix] {
+			return false, fmt.Errorf("immutable tag %s already published with digest %s, not %s", destination, published[destination], built[suffix])
+		}
+	}
+
+	return true, nil
+}
+
+// imageDigest builds the image without publishing it and returns its digest.
+func (p *Plugin) imageDigest(ctx context.Context) (string, error) {
+	build := *p.Build
+	registry := *p.Registry
+
+	// clean up the filesystem after the build, so the published build starts fresh
+	build.Cleanup = true
+	build.DigestFile = digestFile
+	registry.DryRun = true
+
+	pp := &Plugin{
+		Build:    &build,
+		Image:    p.Image,
+		Registry: &registry,
+		Repo:     p.Repo.clone(),
+	}
+
+	logrus.Info("building image to compare with published immutable tags")
+
+	err := execCmd(pp.Command(ctx))
+	if err != nil {
+		return "", fmt.Errorf("unable to build image to verify immutable tags: %w", err)
+	}
+
+	// the file is written by the kaniko executor, so it is read from the OS filesystem
+	data, err := os.ReadFile(digestFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read image digest: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Secrets returns the values that are masked in output from the plugin.
+func (p *Plugin) Secrets() ([]string, error) {
+	// capture the values of any secrets mounted for the step
+	secrets, err := secretFiles()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secrets from %s: %w", secretsDir, err)
+	}
+
+	secrets = append(secrets, p.Registry.secrets()...)
+	secrets = append(secrets, p.Image.secrets()...)
+
+	return secrets, nil
+}
+
+// Sanitize rewrites the tags for the image and each build spec into
+// valid docker tags when sanitizing tags is enabled.
+func (p *Plugin) Sanitize() error {
+	// check if tags should be rewritten
+	if !p.Repo.SanitizeTags {
+		return nil
+	}
+
+	err := p.Repo.Sanitize()
 	if err != nil {
 		return err
 	}
 
+	// build specs are published with the same tag prefix and suffix
+	for i, s := range p.Image.Builds {
+		s.Tags, err = p.Repo.sanitizeTags(s.Tags)
+		if err != nil {
+			return fmt.Errorf("invalid build %d: %w", i+1, err)
+		}
+	}
+
 	return nil
 }
 
@@ -245,5 +624,75 @@ func (p *Plugin) Validate() error {
 		return err
 	}
 
+	// verify the tags for each platform are valid docker tags
+	for _, platform := range p.Image.Platforms {
+		for _, tag := range p.forPlatform(platform).Repo.DestinationTags() {
+			if !tagRegexp.MatchString(tag) {
+				return fmt.Errorf(errTagValidation, tag)
+			}
+		}
+	}
+
+	// capture the build spec publishing each destination
+	destinations := make(map[string]int)
+
+	// validate the configuration for each build spec
+	for i, s := range p.Image.Builds {
+		sp := p.forSpec(s)
+
+		err = sp.Image.Validate()
+		if err == nil {
+			err = sp.Repo.Validate()
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid build %d: %w", i+1, err)
+		}
+
+		// verify the images are not published to the same destination
+		for _, tag := range sp.Repo.DestinationTags() {
+			destination := fmt.Sprintf("%s:%s", sp.Repo.Name, tag)
+
+			if j, ok := destinations[destination]; ok {
+				return fmt.Errorf("invalid build %d: %s is already published by build %d", i+1, destination, j)
+			}
+
+			destinations[destination] = i + 1
+		}
+	}
+
+	// check the repos are hosted on registries with credentials
+	p.warnRepoHosts()
+
 	return nil
 }
+
+// warnRepoHosts logs a warning for each repo hosted on a different
+// registry than the one configured without its own credentials,
+// since the registry credentials would not apply to it.
+func (p *Plugin) warnRepoHosts() {
+	repos := []string{p.Repo.Name}
+
+	// check if repo caching is enabled
+	if p.Repo.Cache {
+		repos = append(repos, p.Repo.CacheRepo())
+	}
+
+	// include the repo for each build spec
+	for _, s := range p.Image.Builds {
+		if len(s.Repo) > 0 && !slices.Contains(repos, s.Repo) {
+			repos = append(repos, s.Repo)
+		}
+	}
+
+	for _, repo := range repos {
+		host, _ := splitRepo(repo)
+
+		// check if the repo is hosted on the registry or has its own credentials
+		if host == registryHost(p.Registry.Name) || p.Registry.hasAuth(host) {
+			continue
+		}
+
+		logrus.Warnf("repo %s is hosted on %s, but credentials are only configured for registry %s", repo, host, p.Registry.Name)
+	}
+}
@@ -3,14 +3,26 @@
 package main
 
 import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
 
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/spf13/afero"
 )
 
+// update enables rewriting the golden files with the generated commands.
+var update = flag.Bool("update", false, "update golden files")
+
 func TestDocker_Plugin_Exec_BadWrite(t *testing.T) {
 	// setup types
 	p := &Plugin{
@@ -168,6 +180,136 @@ func TestDocker_Plugin_Command(t *testing.T) {
 	}
 }
 
+func TestDocker_Plugin_Command_Golden(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name   string
+		plugin func() *Plugin
+	}{
+		{
+			name: "build_args",
+			plugin: func() *Plugin {
+				args, _ := parseKeyValues(`{"VERSION": "1.2.3", "CHECKSUM": "abc", "ARCH": "amd64", "BASE": "alpine"}`)
+
+				return &Plugin{
+					Build: &Build{IgnoreVarRun: true},
+					Image: &Image{
+						Args:       append(args, "BASE=debian", "ARCH=arm64"),
+						Context:    ".",
+						Dockerfile: "Dockerfile",
+					},
+					Registry: &Registry{},
+					Repo: &Repo{
+						Name:              "index.docker.io/target/vela-kaniko",
+						Tags:              []string{"latest"},
+						Label:             testLabel(),
+						CompressedCaching: true,
+					},
+				}
+			},
+		},
+		{
+			name: "labels",
+			plugin: func() *Plugin {
+				label := testLabel()
+				label.CustomSet = []string{"team=octocat", "cost.center=1234"}
+
+				return &Plugin{
+					Build: &Build{IgnoreVarRun: true},
+					Image: &Image{
+						Context:    ".",
+						Dockerfile: "Dockerfile",
+					},
+					Registry: &Registry{},
+					Repo: &Repo{
+						Name:              "index.docker.io/target/vela-kaniko",
+						Tags:              []string{"latest"},
+						Label:             label,
+						Labels:            []string{"org.opencontainers.image.vendor=octocat", "com.example.tier=web"},
+						CompressedCaching: true,
+					},
+				}
+			},
+		},
+		{
+			name: "destinations",
+			plugin: func() *Plugin {
+				return &Plugin{
+					Build: &Build{IgnoreVarRun: true},
+					Image: &Image{
+						Context:    ".",
+						Dockerfile: "Dockerfile",
+					},
+					Registry: &Registry{},
+					Repo: &Repo{
+						Name:              "index.docker.io/target/vela-kaniko",
+						Tags:              []string{"latest", "1.2.3", "1", "1.2", "latest"},
+						TagPrefix:         "v",
+						Label:             testLabel(),
+						CompressedCaching: true,
+					},
+				}
+			},
+		},
+		{
+			name: "platform",
+			plugin: func() *Plugin {
+				p := &Plugin{
+					Build: &Build{IgnoreVarRun: true},
+					Image: &Image{
+						Args:       []string{"foo=bar"},
+						Context:    ".",
+						Dockerfile: "Dockerfile",
+						Platforms:  []string{"linux/amd64", "linux/arm64"},
+					},
+					Registry: &Registry{},
+					Repo: &Repo{
+						Name:              "index.docker.io/target/vela-kaniko",
+						Tags:              []string{"latest", "1.2.3"},
+						Label:             testLabel(),
+						CompressedCaching: true,
+					},
+				}
+
+				return p.forPlatform("linux/arm64")
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join("testdata", "command", test.name+".golden")
+
+			got := strings.Join(test.plugin().Command(t.Context()).Args, "\n") + "\n"
+
+			// check if the golden file should be rewritten
+			if *update {
+				err := os.WriteFile(path, []byte(got), 0644)
+				if err != nil {
+					t.Errorf("WriteFile returned err: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Errorf("ReadFile returned err: %v", err)
+			}
+
+			// verify the command is the same each time it is generated
+			for range 10 {
+				if got != string(want) {
+					t.Errorf("Command is:\n%s\nwant:\n%s", got, want)
+
+					break
+				}
+
+				got = strings.Join(test.plugin().Command(t.Context()).Args, "\n") + "\n"
+			}
+		})
+	}
+}
+
 func TestDocker_Plugin_Command_AutoTag_TagBuild(t *testing.T) {
 	// setup types
 	p := &Plugin{
@@ -205,7 +347,10 @@ func TestDocker_Plugin_Command_AutoTag_TagBuild(t *testing.T) {
 	}
 
 	// configure repo tags using auto_tag and build info
-	p.Repo.ConfigureAutoTagBuildTags(p.Build)
+	err := p.Repo.ConfigureAutoTagBuildTags(p.Build)
+	if err != nil {
+		t.Errorf("ConfigureAutoTagBuildTags returned err: %v", err)
+	}
 
 	want := exec.CommandContext(
 		t.Context(),
@@ -284,7 +429,10 @@ func TestDocker_Plugin_Command_AutoTag_PushBuild(t *testing.T) {
 	}
 
 	// configure repo tags using auto_tag and build info
-	p.Repo.ConfigureAutoTagBuildTags(p.Build)
+	err := p.Repo.ConfigureAutoTagBuildTags(p.Build)
+	if err != nil {
+		t.Errorf("ConfigureAutoTagBuildTags returned err: %v", err)
+	}
 
 	want := exec.CommandContext(
 		t.Context(),
@@ -294,8 +442,8 @@ func TestDocker_Plugin_Command_AutoTag_PushBuild(t *testing.T) {
 		"--cache",
 		"--cache-repo=index.docker.io/target/vela-kaniko",
 		"--context=.",
-		"--destination=index.docker.io/target/vela-kaniko:latest",
 		"--destination=index.docker.io/target/vela-kaniko:7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+		"--destination=index.docker.io/target/vela-kaniko:latest",
 		"--label=org.opencontainers.image.created=now",
 		"--label=org.opencontainers.image.url=git.example.com",
 		"--label=org.opencontainers.image.revision=deadbeef",
@@ -326,6 +474,65 @@ func TestDocker_Plugin_Command_AutoTag_PushBuild(t *testing.T) {
 	}
 }
 
+func TestDocker_Plugin_Command_With_TagPrefixAndSuffix(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			Branch:        "feature/foo",
+			DefaultBranch: "feature/foo",
+			Event:         "push",
+			Sha:           "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+		},
+		Image: &Image{
+			Context:    ".",
+			Dockerfile: "Dockerfile",
+		},
+		Registry: &Registry{
+			Name:   "index.docker.io",
+			DryRun: true,
+		},
+		Repo: &Repo{
+			Name:          "index.docker.io/target/vela-kaniko",
+			Tags:          []string{"latest", "feature-foo", "latest"},
+			AutoTag:       true,
+			AutoTagBranch: true,
+			Label:         testLabel(),
+			TagPrefix:     "v2-",
+			TagSuffix:     "-alpine",
+		},
+	}
+
+	// configure repo tags using auto_tag and build info
+	err := p.Repo.ConfigureAutoTagBuildTags(p.Build)
+	if err != nil {
+		t.Errorf("ConfigureAutoTagBuildTags returned err: %v", err)
+	}
+
+	err = p.Repo.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	want := []string{
+		"--destination=index.docker.io/target/vela-kaniko:v2-7fd1a60b01f91b314f59955a4e4d4e80d8edf11d-alpine",
+		"--destination=index.docker.io/target/vela-kaniko:v2-feature-foo-alpine",
+		"--destination=index.docker.io/target/vela-kaniko:v2-latest-alpine",
+	}
+
+	// run test
+	got := []string{}
+
+	for _, arg := range p.Command(t.Context()).Args {
+		if strings.HasPrefix(arg, "--destination=") {
+			got = append(got, arg)
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Command destinations are %v, want %v", got, want)
+	}
+}
+
 func TestDocker_Plugin_Command_With_Labels(t *testing.T) {
 	// setup types
 	p := &Plugin{
@@ -1188,7 +1395,7 @@ func TestDocker_Plugin_Command_With_Mirror(t *testing.T) {
 		},
 		Registry: &Registry{
 			Name:      "index.docker.io",
-			Mirror:    "company.mirror.io",
+			Mirrors:   []string{"company.mirror.io", "backup.mirror.io:5000"},
 			Username:  "octocat",
 			Password:  "superSecretPassword",
 			DryRun:    true,
@@ -1228,6 +1435,7 @@ func TestDocker_Plugin_Command_With_Mirror(t *testing.T) {
 		"--dockerfile=Dockerfile",
 		"--no-push",
 		"--registry-mirror=company.mirror.io",
+		"--registry-mirror=backup.mirror.io:5000",
 		"--push-retry=1",
 		"--target=foo",
 		"--verbosity=info",
@@ -1626,9 +1834,12 @@ func TestDocker_Plugin_Validate_AutoTag_InvalidBuildTag(t *testing.T) {
 	}
 
 	// configure auto_tag using the invalid build tag
-	p.Repo.ConfigureAutoTagBuildTags(p.Build)
+	err := p.Repo.ConfigureAutoTagBuildTags(p.Build)
+	if err != nil {
+		t.Errorf("ConfigureAutoTagBuildTags returned err: %v", err)
+	}
 
-	err := p.Validate()
+	err = p.Validate()
 	if err == nil {
 		t.Errorf("Validate should have returned err")
 	}
@@ -1760,3 +1971,660 @@ func sortCmdArgs(cmd *exec.Cmd) *exec.Cmd {
 
 	return cmd
 }
+
+func TestDocker_Plugin_checkPush(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// track the probed repositories
+	probed := []string{}
+
+	// setup registry
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			probed = append(probed, r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	// setup tests
+	tests := []struct {
+		name   string
+		dryRun bool
+		cache  string
+		want   []string
+	}{
+		{
+			name: "repo and cache repo",
+			want: []string{
+				"/v2/target/vela-kaniko/blobs/uploads/",
+				"/v2/target/vela-kaniko-cache/blobs/uploads/",
+			},
+			cache: host + "/target/vela-kaniko-cache",
+		},
+		{
+			name: "repo as cache repo",
+			want: []string{
+				"/v2/target/vela-kaniko/blobs/uploads/",
+			},
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+			want: []string{
+				"/v2/target/vela-kaniko/blobs/uploads/",
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			probed = []string{}
+
+			p := &Plugin{
+				Registry: &Registry{
+					Name:               host,
+					Username:           "octocat",
+					Password:           "superSecretPassword",
+					DryRun:             test.dryRun,
+					InsecureRegistries: []string{host},
+					PreflightPush:      true,
+				},
+				Repo: &Repo{
+					Cache:     true,
+					CacheName: test.cache,
+					Name:      host + "/target/vela-kaniko",
+				},
+			}
+
+			err := p.checkPush(t.Context())
+			if err != nil {
+				t.Errorf("checkPush returned err: %v", err)
+			}
+
+			if !reflect.DeepEqual(probed, test.want) {
+				t.Errorf("checkPush probed %v, want %v", probed, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Plugin_checkLatest(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup registry
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/target/vela-kaniko/tags/list":
+			_, _ = w.Write([]byte(`{"name": "target/vela-kaniko", "tags": ["latest", "v1.2.0", "v2.0.0-rc.1", "main"]}`))
+		case "/v2/target/flavors/tags/list":
+			_, _ = w.Write([]byte(`{"name": "target/flavors", "tags": ["latest-alpine", "2.0.0-alpine", "v2-3.0.0", "v2-1.2.0-alpine", "v2-4.0.0-rc.1-alpine"]}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	// setup tests
+	tests := []struct {
+		name   string
+		repo   string
+		tag    string
+		prefix string
+		suffix string
+		want   []string
+	}{
+		{
+			name: "newer version",
+			repo: host + "/target/vela-kaniko",
+			tag:  "v1.3.0",
+			want: []string{"1", "1.3", "1.3.0", "latest"},
+		},
+		{
+			name: "same version",
+			repo: host + "/target/vela-kaniko",
+			tag:  "v1.2.0",
+			want: []string{"1", "1.2", "1.2.0", "latest"},
+		},
+		{
+			name: "older version",
+			repo: host + "/target/vela-kaniko",
+			tag:  "v1.1.5",
+			want: []string{"1", "1.1", "1.1.5"},
+		},
+		{
+			name: "registry error",
+			repo: host + "/target/unavailable",
+			tag:  "v1.1.5",
+			want: []string{"1", "1.1", "1.1.5", "latest"},
+		},
+		{
+			name:   "older version with suffix",
+			repo:   host + "/target/flavors",
+			tag:    "v1.0.0",
+			suffix: "-alpine",
+			want:   []string{"1", "1.0", "1.0.0"},
+		},
+		{
+			name:   "newer version with prefix and suffix",
+			repo:   host + "/target/flavors",
+			tag:    "v1.3.0",
+			prefix: "v2-",
+			suffix: "-alpine",
+			want:   []string{"1", "1.3", "1.3.0", "latest"},
+		},
+		{
+			name:   "older version with prefix and suffix",
+			repo:   host + "/target/flavors",
+			tag:    "v1.1.5",
+			prefix: "v2-",
+			suffix: "-alpine",
+			want:   []string{"1", "1.1", "1.1.5"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Plugin{
+				Registry: &Registry{
+					Name:               host,
+					InsecureRegistries: []string{host},
+				},
+				Repo: &Repo{
+					AutoTag:      true,
+					Name:         test.repo,
+					SemverTags:   true,
+					SemverLatest: true,
+					TagPrefix:    test.prefix,
+					TagSuffix:    test.suffix,
+				},
+			}
+
+			err := p.Repo.ConfigureAutoTagBuildTags(&Build{
+				Event: "tag",
+				Tag:   test.tag,
+			})
+			if err != nil {
+				t.Errorf("ConfigureAutoTagBuildTags returned err: %v", err)
+			}
+
+			p.checkLatest(t.Context())
+
+			if !reflect.DeepEqual(p.Repo.Tags, test.want) {
+				t.Errorf("checkLatest is %v, want %v", p.Repo.Tags, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Plugin_checkImmutable(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// restore the kaniko executor and digest file after the test
+	bin, file := kanikoBin, digestFile
+
+	t.Cleanup(func() { kanikoBin, digestFile = bin, file })
+
+	// setup executor which writes the digest of the built image
+	dir := t.TempDir()
+
+	digestFile = filepath.Join(dir, "digest")
+	kanikoBin = filepath.Join(dir, "executor")
+
+	// the image must be built reproducibly in a clean filesystem without publishing it
+	script := "#!/bin/sh\n" +
+		"for flag in --cleanup --reproducible --no-push --digest-file=" + digestFile + "; do\n" +
+		"  case \" $* \" in *\" $flag \"*) ;; *) exit 2 ;; esac\n" +
+		"done\n" +
+		"echo sha256:built > " + digestFile + "\n"
+
+	err := os.WriteFile(kanikoBin, []byte(script), 0755)
+	if err != nil {
+		t.Errorf("WriteFile returned err: %v", err)
+	}
+
+	// setup registry
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/target/vela-kaniko/manifests/1.2.3-alpine", "/v2/target/vela-kaniko/manifests/latest-alpine":
+			w.Header().Set("Docker-Content-Digest", "sha256:published")
+			w.WriteHeader(http.StatusOK)
+		case "/v2/target/vela-kaniko/manifests/1.2.4-alpine",
+			"/v2/target/vela-kaniko/manifests/2.0.0-alpine-linux-amd64", "/v2/target/vela-kaniko/manifests/2.0.0-alpine-linux-arm64",
+			"/v2/target/vela-kaniko/manifests/2.0.1-alpine-linux-amd64":
+			w.Header().Set("Docker-Content-Digest", "sha256:built")
+			w.WriteHeader(http.StatusOK)
+		case "/v2/target/vela-kaniko/manifests/2.0.0-alpine", "/v2/target/vela-kaniko/manifests/2.0.1-alpine":
+			w.Header().Set("Docker-Content-Digest", "sha256:index")
+			w.WriteHeader(http.StatusOK)
+		case "/v2/target/vela-kaniko/manifests/2.0.2-alpine-linux-arm64":
+			w.Header().Set("Docker-Content-Digest", "sha256:published")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	// setup tests
+	tests := []struct {
+		name         string
+		action       string
+		reproducible bool
+		platforms    []string
+		tags         []string
+		want         []string
+		wantErr      bool
+	}{
+		{
+			name:   "new immutable tag",
+			action: immutableFail,
+			tags:   []string{"latest", "1.2.5"},
+			want:   []string{"latest", "1.2.5"},
+		},
+		{
+			name:         "published immutable tag with same image",
+			action:       immutableFail,
+			reproducible: true,
+			tags:         []string{"latest", "1.2.4"},
+			want:         []string{"latest"},
+		},
+		{
+			name:    "published immutable tag without reproducible build",
+			action:  immutableFail,
+			tags:    []string{"latest", "1.2.4"},
+			want:    []string{"latest", "1.2.4"},
+			wantErr: true,
+		},
+		{
+			name:         "published immutable tag",
+			action:       immutableFail,
+			reproducible: true,
+			tags:         []string{"latest", "1.2.3"},
+			want:         []string{"latest", "1.2.3"},
+			wantErr:      true,
+		},
+		{
+			name:         "skip published immutable tag",
+			action:       immutableSkip,
+			reproducible: true,
+			tags:         []string{"latest", "1.2", "1.2.3"},
+			want:         []string{"latest", "1.2"},
+		},
+		{
+			name:         "published immutable index with same images",
+			action:       immutableFail,
+			reproducible: true,
+			platforms:    []string{"linux/amd64", "linux/arm64"},
+			tags:         []string{"latest", "2.0.0"},
+			want:         []string{"latest"},
+		},
+		{
+			name:         "published immutable index missing platform image",
+			action:       immutableFail,
+			reproducible: true,
+			platforms:    []string{"linux/amd64", "linux/arm64"},
+			tags:         []string{"latest", "2.0.1"},
+			want:         []string{"latest", "2.0.1"},
+			wantErr:      true,
+		},
+		{
+			name:         "skip published immutable platform image",
+			action:       immutableSkip,
+			reproducible: true,
+			platforms:    []string{"linux/amd64", "linux/arm64"},
+			tags:         []string{"latest", "2.0.2"},
+			want:         []string{"latest"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Plugin{
+				Build: &Build{
+					Reproducible: test.reproducible,
+				},
+				Image: &Image{
+					Context:    ".",
+					Dockerfile: "Dockerfile",
+					Platforms:  test.platforms,
+				},
+				Registry: &Registry{
+					Name:               host,
+					InsecureRegistries: []string{host},
+				},
+				Repo: &Repo{
+					Label:               testLabel(),
+					Name:                host + "/target/vela-kaniko",
+					Tags:                test.tags,
+					ImmutableTags:       `^\d+\.\d+\.\d+`,
+					ImmutableTagsAction: test.action,
+					TagSuffix:           "-alpine",
+				},
+			}
+
+			err := p.checkImmutable(t.Context())
+			if (err != nil) != test.wantErr {
+				t.Errorf("checkImmutable returned err: %v, want err %v", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(p.Repo.Tags, test.want) {
+				t.Errorf("checkImmutable tags are %v, want %v", p.Repo.Tags, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Plugin_build_Immutable(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// restore the kaniko executor and digest file after the test
+	bin, file := kanikoBin, digestFile
+
+	t.Cleanup(func() { kanikoBin, digestFile = bin, file })
+
+	// setup executor which records the arguments of each run
+	dir := t.TempDir()
+
+	digestFile = filepath.Join(dir, "digest")
+	kanikoBin = filepath.Join(dir, "executor")
+
+	runs := filepath.Join(dir, "runs")
+
+	script := "#!/bin/sh\n" +
+		"echo \"$*\" >> " + runs + "\n" +
+		"echo sha256:built > " + digestFile + "\n"
+
+	err := os.WriteFile(kanikoBin, []byte(script), 0755)
+	if err != nil {
+		t.Errorf("WriteFile returned err: %v", err)
+	}
+
+	// setup registry
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/target/vela-kaniko/manifests/1.2.4":
+			w.Header().Set("Docker-Content-Digest", "sha256:built")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	p := &Plugin{
+		Build: &Build{
+			Reproducible: true,
+		},
+		Image: &Image{
+			Context:    ".",
+			Dockerfile: "Dockerfile",
+		},
+		Registry: &Registry{
+			Name:               host,
+			InsecureRegistries: []string{host},
+		},
+		Repo: &Repo{
+			Label:               testLabel(),
+			Name:                host + "/target/vela-kaniko",
+			Tags:                []string{"latest", "1.2.4"},
+			ImmutableTags:       `^\d+\.\d+\.\d+`,
+			ImmutableTagsAction: immutableFail,
+			CompressedCaching:   true,
+		},
+	}
+
+	// run test
+	err = p.build(t.Context())
+	if err != nil {
+		t.Errorf("build returned err: %v", err)
+	}
+
+	data, err := os.ReadFile(runs)
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	got := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	if len(got) != 2 {
+		t.Errorf("build ran kaniko %d times, want 2", len(got))
+
+		return
+	}
+
+	// verify the image is first built without publishing it
+	if !slices.Contains(strings.Fields(got[0]), "--no-push") {
+		t.Errorf("build compared with %s, want --no-push", got[0])
+	}
+
+	// verify the image is only published with the tags not already published
+	want := []string{
+		"--reproducible",
+		"--ignore-var-run=false",
+		"--context=.",
+		"--destination=" + host + "/target/vela-kaniko:latest",
+		"--dockerfile=Dockerfile",
+		"--insecure-registry=" + host,
+		"--verbosity=" + logrus.GetLevel().String(),
+		"--label=io.vela.build.author=octocat@example.com",
+		"--label=io.vela.build.commit=deadbeef",
+		"--label=io.vela.build.host=vela-worker",
+		"--label=io.vela.build.link=https://vela.example.com/velaOrg/velaRepo/1",
+		"--label=io.vela.build.number=1",
+		"--label=io.vela.build.repo=octocat/scripts",
+		"--label=io.vela.build.topics=id123",
+		"--label=io.vela.build.url=git.example.com",
+		"--label=org.opencontainers.image.created=now",
+		"--label=org.opencontainers.image.revision=deadbeef",
+		"--label=org.opencontainers.image.url=git.example.com",
+	}
+
+	if !reflect.DeepEqual(strings.Fields(got[1]), want) {
+		t.Errorf("build published with %v, want %v", strings.Fields(got[1]), want)
+	}
+}
+
+func TestDocker_Plugin_Validate_RepoHostMismatch(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name        string
+		repo        string
+		credentials []*Credential
+		want        int
+	}{
+		{
+			name: "same registry",
+			repo: "docker.io/target/vela-kaniko",
+			want: 0,
+		},
+		{
+			name: "different registry",
+			repo: "harbor.example.com/target/vela-kaniko",
+			want: 1,
+		},
+		{
+			name: "different registry with credentials",
+			repo: "harbor.example.com/target/vela-kaniko",
+			credentials: []*Credential{
+				{Registry: "harbor.example.com", Username: "octocat", Password: "superSecretPassword"},
+			},
+			want: 0,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := logtest.NewGlobal()
+			defer hook.Reset()
+
+			p := &Plugin{
+				Build: &Build{
+					Event: "push",
+					Sha:   "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+				},
+				Image: &Image{
+					Context:    ".",
+					Dockerfile: "Dockerfile",
+				},
+				Registry: &Registry{
+					Name:        "index.docker.io",
+					Username:    "octocat",
+					Password:    "superSecretPassword",
+					Credentials: test.credentials,
+				},
+				Repo: &Repo{
+					Name:  test.repo,
+					Tags:  []string{"latest"},
+					Label: &Label{},
+				},
+			}
+
+			err := p.Validate()
+			if err != nil {
+				t.Errorf("Validate returned err: %v", err)
+			}
+
+			got := 0
+
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == logrus.WarnLevel {
+					got++
+				}
+			}
+
+			if got != test.want {
+				t.Errorf("Validate logged %d warnings, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Plugin_Exec_Cleanup(t *testing.T) {
+	// restore the kaniko executor after the test
+	bin := kanikoBin
+
+	t.Cleanup(func() { kanikoBin = bin })
+
+	// setup tests
+	tests := []struct {
+		name     string
+		bin      string
+		existing string
+		failure  bool
+	}{
+		{
+			name:    "success",
+			bin:     "true",
+			failure: false,
+		},
+		{
+			name:    "failure",
+			bin:     "false",
+			failure: true,
+		},
+		{
+			name:     "success with existing file",
+			bin:      "true",
+			existing: `{"proxies": {}}`,
+			failure:  false,
+		},
+		{
+			name:     "failure with existing file",
+			bin:      "false",
+			existing: `{"proxies": {}}`,
+			failure:  true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			if len(test.existing) > 0 {
+				err := afero.WriteFile(appFS, "/kaniko/.docker/config.json", []byte(test.existing), 0644)
+				if err != nil {
+					t.Errorf("WriteFile returned err: %v", err)
+				}
+			}
+
+			kanikoBin = test.bin
+
+			p := &Plugin{
+				Build: &Build{
+					Event:        "push",
+					Sha:          "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+					IgnoreVarRun: true,
+				},
+				Image: &Image{
+					Context:    ".",
+					Dockerfile: "Dockerfile",
+				},
+				Registry: &Registry{
+					Name:     "index.docker.io",
+					Username: "octocat",
+					Password: "superSecretPassword",
+				},
+				Repo: &Repo{
+					Name:  "index.docker.io/target/vela-kaniko",
+					Tags:  []string{"latest"},
+					Label: testLabel(),
+				},
+			}
+
+			err := p.Exec(t.Context())
+
+			if test.failure && err == nil {
+				t.Errorf("Exec should have returned err")
+			}
+
+			if !test.failure && err != nil {
+				t.Errorf("Exec returned err: %v", err)
+			}
+
+			// check the state of the config.json file
+			got, err := afero.ReadFile(appFS, "/kaniko/.docker/config.json")
+
+			if len(test.existing) == 0 {
+				if err == nil {
+					t.Errorf("config.json should have been removed, contains %s", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Errorf("ReadFile returned err: %v", err)
+			}
+
+			if string(got) != test.existing {
+				t.Errorf("config.json is %s, want %s", got, test.existing)
+			}
+
+			info, _ := appFS.Stat("/kaniko/.docker/config.json")
+
+			if info.Mode().Perm() != 0644 {
+				t.Errorf("config.json mode is %v, want 0644", info.Mode().Perm())
+			}
+		})
+	}
+}
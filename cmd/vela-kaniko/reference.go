@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+const (
+	// dockerHubHost defines the host used for communicating with Docker Hub.
+	dockerHubHost = "index.docker.io"
+
+	// dockerHubAuthKey defines the key used for Docker Hub
+	// in the auths section of the Docker config.json file.
+	dockerHubAuthKey = "https://index.docker.io/v1/"
+)
+
+// registryHost returns the canonical host for a registry name, so that names
+// such as "docker.io", "index.docker.io" and "https://index.docker.io/v1/"
+// all refer to the same registry.
+func registryHost(name string) string {
+	// remove any scheme and path from the name
+	host := strings.TrimPrefix(strings.TrimPrefix(name, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	host = strings.ToLower(host)
+
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return dockerHubHost
+	default:
+		return host
+	}
+}
+
+// authKey returns the canonical key for a registry name
+// in the auths section of the Docker config.json file.
+func authKey(name string) string {
+	// check if the name is scoped to a repository, such as those
+	// in a containers auth.json file, which are kept as-is
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(name, "https://"), "http://")
+	if _, path, _ := strings.Cut(trimmed, "/"); len(strings.Trim(path, "/")) > 0 &&
+		path != "v1/" && path != "v2/" && path != "v1" && path != "v2" {
+		return name
+	}
+
+	host := registryHost(name)
+	if host == dockerHubHost {
+		return dockerHubAuthKey
+	}
+
+	return host
+}
+
+// parseRepo parses the name of a repository per the distribution reference grammar.
+//
+// https://github.com/distribution/reference/blob/main/reference.go
+func parseRepo(name string) (reference.Named, error) {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repo name %s: %w", name, err)
+	}
+
+	// verify the name does not include a tag or digest
+	if !reference.IsNameOnly(named) {
+		return nil, fmt.Errorf("invalid repo name %s: tags and digests must not be included", name)
+	}
+
+	return named, nil
+}
+
+// splitRepo splits the name of a repository into the registry host and repository path.
+func splitRepo(name string) (string, string) {
+	named, err := parseRepo(name)
+	if err != nil {
+		return registryHost(name), name
+	}
+
+	return registryHost(reference.Domain(named)), reference.Path(named)
+}
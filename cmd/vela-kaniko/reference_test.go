@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import "testing"
+
+func TestDocker_registryHost(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "docker.io", want: "index.docker.io"},
+		{name: "index.docker.io", want: "index.docker.io"},
+		{name: "https://index.docker.io/v1/", want: "index.docker.io"},
+		{name: "Registry.Example.com:5000", want: "registry.example.com:5000"},
+		{name: "https://registry.example.com/v2/", want: "registry.example.com"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := registryHost(test.name)
+
+		if got != test.want {
+			t.Errorf("registryHost for %s is %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDocker_authKey(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "docker.io", want: "https://index.docker.io/v1/"},
+		{name: "index.docker.io", want: "https://index.docker.io/v1/"},
+		{name: "https://index.docker.io/v1/", want: "https://index.docker.io/v1/"},
+		{name: "registry.example.com:5000", want: "registry.example.com:5000"},
+		{name: "https://registry.example.com/v2/", want: "registry.example.com"},
+		{name: "quay.io/octocat", want: "quay.io/octocat"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := authKey(test.name)
+
+		if got != test.want {
+			t.Errorf("authKey for %s is %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDocker_splitRepo(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name string
+		host string
+		path string
+	}{
+		{name: "index.docker.io/target/vela-kaniko", host: "index.docker.io", path: "target/vela-kaniko"},
+		{name: "docker.io/target/vela-kaniko", host: "index.docker.io", path: "target/vela-kaniko"},
+		{name: "target/vela-kaniko", host: "index.docker.io", path: "target/vela-kaniko"},
+		{name: "alpine", host: "index.docker.io", path: "library/alpine"},
+		{name: "localhost/vela-kaniko", host: "localhost", path: "vela-kaniko"},
+		{name: "registry.example.com:5000/target/vela-kaniko", host: "registry.example.com:5000", path: "target/vela-kaniko"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		host, path := splitRepo(test.name)
+
+		if host != test.host || path != test.path {
+			t.Errorf("splitRepo is %s %s, want %s %s", host, path, test.host, test.path)
+		}
+	}
+}
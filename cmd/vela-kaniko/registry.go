@@ -3,39 +3,70 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 )
 
-const (
-	credentials = `%s:%s`
+const credentials = `%s:%s`
 
-	registryFile = `{
-  "auths": {
-    "%s": {
-      "auth": "%s"
-    }
-  }
-}`
-)
+// regular expression to validate registry mirrors as host[:port]
+var mirrorRegexp = regexp.MustCompile(`^(localhost|\[[0-9a-fA-F:]+\]|[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*)(:[0-9]{1,5})?$`)
+
+// Credential represents the authentication for an additional registry.
+type Credential struct {
+	// name of the registry to authenticate with
+	Registry string `json:"registry"`
+	// user name for communication with the registry
+	Username string `json:"username"`
+	// password for communication with the registry
+	Password string `json:"password"`
+	// OAuth refresh token for communication with the registry
+	IdentityToken string `json:"identitytoken"`
+	// bearer token for communication with the registry
+	RegistryToken string `json:"registrytoken"`
+}
 
 // Registry represents the plugin configuration for registry information.
 //
 // https://docs.docker.com/registry/
 type Registry struct {
+	// credentials for additional registries to push/pull from
+	Credentials []*Credential
+	// credential helpers to use for specific registries
+	CredHelpers map[string]string
+	// default credential store to use for all registries
+	CredsStore string
+	// full Docker config.json or containers auth.json document
+	DockerConfig string
 	// insecure registries to push/pull from
 	InsecureRegistries []string
-	// name of the mirror registry to use instead of index.docker.io
-	Mirror string
+	// names of the mirror registries to use instead of index.docker.io, tried in order
+	Mirrors []string
+	// user name for communication with the mirror registries
+	MirrorUsername string
+	// password for communication with the mirror registries
+	MirrorPassword string
+	// bearer token for communication with the mirror registries
+	MirrorToken string
 	// name of the registry to publish the image to
 	Name string
 	// user name for communication with the registry
 	Username string
 	// password for communication with the registry
 	Password string
+	// OAuth refresh token for communication with the registry
+	IdentityToken string
+	// bearer token for communication with the registry
+	RegistryToken string
 	// enable building the image without publishing
 	PushRetry int
 	// enable pulling from any insecure registry
@@ -44,9 +75,26 @@ type Registry struct {
 	InsecurePull bool
 	// enable pushing to any insecure registry
 	InsecurePush bool
+	// enable verifying authentication with the registry before building
+	Preflight bool
+	// enable verifying push permission for the repositories before building
+	PreflightPush bool
+
+	// path of the Docker config.json file written for the build
+	written string
+	// contents of the Docker config.json file before it was written
+	original []byte
+	// mode of the Docker config.json file before it was written
+	originalMode os.FileMode
+	// whether the Docker config.json file existed before it was written
+	existed bool
 }
 
 // Write creates a Docker config.json file for building and publishing the image.
+//
+// Any existing config.json file is preserved, with the entries from the
+// provided Docker config and then the plugin configuration replacing
+// those for the same registry.
 func (r *Registry) Write() error {
 	logrus.Trace("writing registry configuration file")
 
@@ -55,28 +103,333 @@ func (r *Registry) Write() error {
 		Fs: appFS,
 	}
 
-	// check if name, username and password are provided
-	if len(r.Name) == 0 || len(r.Username) == 0 || len(r.Password) == 0 {
+	// create config.json contents from the plugin configuration
+	plugin := r.config()
+
+	// capture the provided Docker config
+	provided, err := parseDockerConfig(r.DockerConfig)
+	if err != nil {
+		return err
+	}
+
+	// check if any authentication or credential helper was provided
+	if plugin.empty() && provided.empty() {
 		return nil
 	}
 
-	// create basic authentication string for config.json file
-	basicAuth := base64.StdEncoding.EncodeToString(
-		[]byte(fmt.Sprintf(credentials, r.Username, r.Password)),
-	)
+	// merge the plugin configuration into any existing config.json file
+	config, err := r.merged()
+	if err != nil {
+		return err
+	}
 
-	// create output string for config.json file
-	out := fmt.Sprintf(
-		registryFile,
-		r.Name,
-		basicAuth,
-	)
+	// create output for config.json file
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
 
 	// create full path for config.json file
-	path := "/kaniko/.docker/config.json"
+	path := dockerConfigPath()
+
+	// capture any existing config.json file so it can be restored
+	info, err := a.Stat(path)
+	if err == nil {
+		r.original, err = a.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		r.originalMode = info.Mode().Perm()
+		r.existed = true
+	}
+
+	// write to a temporary file, which is only readable by the owner,
+	// so the credentials are never stored with the existing permissions
+	f, err := a.TempFile(filepath.Dir(path), "config-*.json")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(out)
+	if err != nil {
+		_ = f.Close()
+		_ = a.Remove(f.Name())
+
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		_ = a.Remove(f.Name())
+
+		return err
+	}
+
+	err = a.Rename(f.Name(), path)
+	if err != nil {
+		_ = a.Remove(f.Name())
+
+		return err
+	}
+
+	r.written = path
+
+	return nil
+}
+
+// Cleanup removes the Docker config.json file written for the build,
+// restoring any file that existed before it was written.
+func (r *Registry) Cleanup() error {
+	// check if a config.json file was written
+	if len(r.written) == 0 {
+		return nil
+	}
+
+	logrus.Trace("cleaning up registry configuration file")
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	path := r.written
+	r.written = ""
+
+	// check if the config.json file existed before it was written
+	if !r.existed {
+		err := a.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	err := a.WriteFile(path, r.original, r.originalMode)
+	if err != nil {
+		return err
+	}
+
+	return a.Chmod(path, r.originalMode)
+}
+
+// CheckAuth verifies authentication with the registry for pushing to the repository.
+func (r *Registry) CheckAuth(ctx context.Context, repo string) error {
+	logrus.Infof("verifying authentication with registry %s", r.Name)
+
+	_, path := splitRepo(repo)
+
+	c, err := newRegistryClient(r, r.Name)
+	if err != nil {
+		return err
+	}
+
+	return c.ping(ctx, fmt.Sprintf("repository:%s:pull,push", path))
+}
+
+// CheckPush verifies the credentials allow pushing to the repository.
+func (r *Registry) CheckPush(ctx context.Context, repo string) error {
+	logrus.Infof("verifying push permission for repository %s", repo)
+
+	host, path := splitRepo(repo)
+
+	c, err := newRegistryClient(r, host)
+	if err != nil {
+		return err
+	}
+
+	return c.probePush(ctx, path)
+}
+
+// ListTags lists the tags published to the repository.
+func (r *Registry) ListTags(ctx context.Context, repo string) ([]string, error) {
+	logrus.Debugf("listing tags for repository %s", repo)
+
+	host, path := splitRepo(repo)
+
+	c, err := newRegistryClient(r, host)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.tags(ctx, path)
+}
+
+// TagDigest returns the digest of the image published to the repository
+// under the tag, or an empty digest if the tag is not published.
+func (r *Registry) TagDigest(ctx context.Context, repo, tag string) (string, error) {
+	logrus.Debugf("checking for tag %s in repository %s", tag, repo)
+
+	host, path := splitRepo(repo)
+
+	c, err := newRegistryClient(r, host)
+	if err != nil {
+		return "", err
+	}
+
+	return c.manifestDigest(ctx, path, tag)
+}
+
+// PushIndex publishes an image index, referencing the image published to
+// the repository for each platform, under each of the tags.
+//
+// A Docker manifest list is published when all images are Docker
+// manifests, and otherwise an OCI image index is published.
+func (r *Registry) PushIndex(ctx context.Context, repo string, images []*platformImage, tags []string) error {
+	logrus.Debugf("publishing image index for repository %s", repo)
+
+	host, path := splitRepo(repo)
+
+	c, err := newRegistryClient(r, host)
+	if err != nil {
+		return err
+	}
+
+	index := &imageIndex{
+		SchemaVersion: 2,
+		MediaType:     dockerListMediaType,
+	}
+
+	for _, image := range images {
+		d, err := c.manifest(ctx, path, image.Tag)
+		if err != nil {
+			return fmt.Errorf("unable to fetch image for platform %s: %w", image.Platform, err)
+		}
+
+		// a Docker manifest list may only reference Docker manifests
+		if d.MediaType != dockerManifestMediaType {
+			index.MediaType = ociIndexMediaType
+		}
+
+		d.Platform, err = parsePlatform(image.Platform)
+		if err != nil {
+			return err
+		}
+
+		index.Manifests = append(index.Manifests, d)
+	}
+
+	body, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		logrus.Infof("publishing image index %s:%s for %d platforms", repo, tag, len(images))
+
+		err = c.putManifest(ctx, path, tag, index.MediaType, body)
+		if err != nil {
+			return fmt.Errorf("unable to publish image index %s:%s: %w", repo, tag, err)
+		}
+	}
+
+	return nil
+}
 
-	//nolint: gomnd // ignore magic number
-	return a.WriteFile(path, []byte(out), 0644)
+// merged creates the Docker config.json contents by merging the provided
+// Docker config and then the plugin configuration into any existing file.
+func (r *Registry) merged() (*dockerConfig, error) {
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	// capture the provided Docker config
+	provided, err := parseDockerConfig(r.DockerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// capture any existing config.json file
+	config, err := readDockerConfig(a, dockerConfigPath())
+	if err != nil {
+		return nil, err
+	}
+
+	config.merge(provided)
+	config.merge(r.config())
+
+	return config, nil
+}
+
+// config creates the Docker config.json contents from the plugin configuration.
+func (r *Registry) config() *dockerConfig {
+	config := &dockerConfig{
+		Auths:       make(map[string]dockerAuth),
+		CredHelpers: r.CredHelpers,
+		CredsStore:  r.CredsStore,
+	}
+
+	// check if name and a password or token are provided
+	if len(r.Name) > 0 && r.credential().hasSecret() {
+		config.Auths[authKey(r.Name)] = r.credential().auth()
+	}
+
+	// add the credentials for any additional registries
+	for _, c := range r.Credentials {
+		config.Auths[authKey(c.Registry)] = c.auth()
+	}
+
+	// add the credentials for any mirror registries
+	for _, c := range r.mirrorCredentials() {
+		// check if a password or token is provided for the mirror
+		if c.hasSecret() {
+			config.Auths[authKey(c.Registry)] = c.auth()
+		}
+	}
+
+	return config
+}
+
+// mirrorCredentials creates the Credential for each of the mirror registries.
+func (r *Registry) mirrorCredentials() []*Credential {
+	credentials := []*Credential{}
+
+	for _, mirror := range r.Mirrors {
+		credentials = append(credentials, &Credential{
+			Registry:      mirror,
+			Username:      r.MirrorUsername,
+			Password:      r.MirrorPassword,
+			RegistryToken: r.MirrorToken,
+		})
+	}
+
+	return credentials
+}
+
+// credential creates the Credential for the registry the image is published to.
+func (r *Registry) credential() *Credential {
+	return &Credential{
+		Registry:      r.Name,
+		Username:      r.Username,
+		Password:      r.Password,
+		IdentityToken: r.IdentityToken,
+		RegistryToken: r.RegistryToken,
+	}
+}
+
+// secrets returns the passwords and tokens from the registry configuration.
+func (r *Registry) secrets() []string {
+	configs := []*dockerConfig{r.config()}
+
+	// capture the provided Docker config, which is validated separately
+	provided, err := parseDockerConfig(r.DockerConfig)
+	if err == nil {
+		configs = append(configs, provided)
+	}
+
+	secrets := []string{}
+
+	for _, config := range configs {
+		for _, auth := range config.Auths {
+			_, password := auth.credentials()
+
+			secrets = append(secrets, auth.Auth, password, auth.IdentityToken, auth.RegistryToken)
+		}
+	}
+
+	return secrets
 }
 
 // Validate verifies the Registry is properly configured.
@@ -88,18 +441,166 @@ func (r *Registry) Validate() error {
 		return fmt.Errorf("no registry name provided")
 	}
 
-	// check if dry run is disabled
-	if !r.DryRun {
-		// check if username is provided
-		if len(r.Username) == 0 {
-			return fmt.Errorf("no registry username provided")
+	// verify the provided Docker config is valid
+	_, err := parseDockerConfig(r.DockerConfig)
+	if err != nil {
+		return err
+	}
+
+	// check if dry run is disabled and no other authentication is provided for the registry
+	if !r.DryRun && !r.hasExternalAuth(r.Name) {
+		err := r.credential().Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// check each of the credential helpers
+	for registry, helper := range r.CredHelpers {
+		// verify registry is provided
+		if len(registry) == 0 {
+			return fmt.Errorf("no registry name provided for credential helper %s", helper)
+		}
+
+		// verify helper is provided
+		if len(helper) == 0 {
+			return fmt.Errorf("no credential helper provided for %s", registry)
+		}
+	}
+
+	// track the registries with credentials to catch duplicates
+	registries := make(map[string]bool)
+
+	if r.credential().hasSecret() {
+		registries[authKey(r.Name)] = true
+	}
+
+	// check each of the additional registry credentials
+	for i, c := range r.Credentials {
+		// verify the entry is provided, such as not a null in the list
+		if c == nil {
+			return fmt.Errorf("no credentials provided for credentials entry %d", i)
 		}
 
-		// check if password is provided
-		if len(r.Password) == 0 {
-			return fmt.Errorf("no registry password provided")
+		// verify registry is provided
+		if len(c.Registry) == 0 {
+			return fmt.Errorf("no registry name provided for credentials entry %d", i)
+		}
+
+		// verify the registry is only provided once
+		if registries[authKey(c.Registry)] {
+			return fmt.Errorf("duplicate credentials provided for registry %s", c.Registry)
+		}
+
+		registries[authKey(c.Registry)] = true
+
+		err := c.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// check each of the mirror registries
+	for _, mirror := range r.Mirrors {
+		// verify the mirror is a valid host[:port]
+		if !mirrorRegexp.MatchString(mirror) {
+			return fmt.Errorf("registry mirror %s is not in the format host[:port]", mirror)
+		}
+	}
+
+	// check if mirror credentials are provided
+	if len(r.MirrorUsername) > 0 || len(r.MirrorPassword) > 0 || len(r.MirrorToken) > 0 {
+		// verify a mirror is provided for the credentials
+		if len(r.Mirrors) == 0 {
+			return fmt.Errorf("no registry mirror provided for mirror credentials")
+		}
+
+		for _, c := range r.mirrorCredentials() {
+			err := c.Validate()
+			if err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
+
+// hasAuth checks if any authentication is provided for the registry.
+func (r *Registry) hasAuth(registry string) bool {
+	_, ok := r.config().lookup(registry)
+
+	return ok || r.hasExternalAuth(registry)
+}
+
+// hasExternalAuth checks if authentication for the registry is
+// provided by a credential helper or the provided Docker config.
+func (r *Registry) hasExternalAuth(registry string) bool {
+	// check if a credential helper is configured for the registry
+	if len(r.CredsStore) > 0 || hasHelper(r.CredHelpers, registry) {
+		return true
+	}
+
+	config, err := parseDockerConfig(r.DockerConfig)
+	if err != nil {
+		return false
+	}
+
+	_, ok := config.lookup(registry)
+
+	return ok || len(config.CredsStore) > 0 || hasHelper(config.CredHelpers, registry)
+}
+
+// hasHelper checks if a credential helper is configured for the registry.
+func hasHelper(helpers map[string]string, registry string) bool {
+	for name, helper := range helpers {
+		if registryHost(name) == registryHost(registry) && len(helper) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate verifies the Credential is properly configured.
+func (c *Credential) Validate() error {
+	// check if a token is provided in place of a password
+	if len(c.IdentityToken) > 0 || len(c.RegistryToken) > 0 {
+		return nil
+	}
+
+	// check if username is provided
+	if len(c.Username) == 0 {
+		return fmt.Errorf("no registry username provided for %s", c.Registry)
+	}
+
+	// check if password is provided
+	if len(c.Password) == 0 {
+		return fmt.Errorf("no registry password provided for %s", c.Registry)
+	}
+
+	return nil
+}
+
+// hasSecret checks if a password or token is provided for the Credential.
+func (c *Credential) hasSecret() bool {
+	return (len(c.Username) > 0 && len(c.Password) > 0) ||
+		len(c.IdentityToken) > 0 || len(c.RegistryToken) > 0
+}
+
+// auth creates the entry for the auths section of the Docker config.json file.
+func (c *Credential) auth() dockerAuth {
+	auth := dockerAuth{
+		IdentityToken: c.IdentityToken,
+		RegistryToken: c.RegistryToken,
+	}
+
+	// check if basic authentication is provided
+	if len(c.Username) > 0 || len(c.Password) > 0 {
+		auth.Auth = base64.StdEncoding.EncodeToString(
+			[]byte(fmt.Sprintf(credentials, c.Username, c.Password)),
+		)
+	}
+
+	return auth
+}
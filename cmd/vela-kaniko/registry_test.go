@@ -3,6 +3,7 @@
 package main
 
 import (
+	"os"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -133,3 +134,678 @@ func TestDocker_Registry_Write_NoPassword(t *testing.T) {
 		t.Errorf("Write returned err: %v", err)
 	}
 }
+
+func TestDocker_Registry_Validate_Credentials(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+		Credentials: []*Credential{
+			{
+				Registry: "artifactory.example.com",
+				Username: "octocat",
+				Password: "superSecretPassword",
+			},
+			{
+				Registry: "harbor.example.com",
+				Username: "octocat",
+				Password: "superSecretPassword",
+			},
+		},
+	}
+
+	err := r.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+}
+
+func TestDocker_Registry_Validate_Credentials_Invalid(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name        string
+		credentials []*Credential
+	}{
+		{
+			name: "no registry",
+			credentials: []*Credential{
+				{Username: "octocat", Password: "superSecretPassword"},
+			},
+		},
+		{
+			name: "no username",
+			credentials: []*Credential{
+				{Registry: "harbor.example.com", Password: "superSecretPassword"},
+			},
+		},
+		{
+			name: "no password",
+			credentials: []*Credential{
+				{Registry: "harbor.example.com", Username: "octocat"},
+			},
+		},
+		{
+			name: "duplicate registry",
+			credentials: []*Credential{
+				{Registry: "index.docker.io", Username: "octocat", Password: "superSecretPassword"},
+			},
+		},
+		{
+			name:        "null entry",
+			credentials: []*Credential{nil},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Registry{
+				Name:        "index.docker.io",
+				Username:    "octocat",
+				Password:    "superSecretPassword",
+				Credentials: test.credentials,
+			}
+
+			err := r.Validate()
+			if err == nil {
+				t.Errorf("Validate should have returned err")
+			}
+		})
+	}
+}
+
+func TestDocker_Registry_Write_Credentials(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+		Credentials: []*Credential{
+			{
+				Registry: "harbor.example.com",
+				Username: "octokitty",
+				Password: "superSecretPassword",
+			},
+		},
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, "/kaniko/.docker/config.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := `{
+  "auths": {
+    "harbor.example.com": {
+      "auth": "b2N0b2tpdHR5OnN1cGVyU2VjcmV0UGFzc3dvcmQ="
+    },
+    "https://index.docker.io/v1/": {
+      "auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"
+    }
+  }
+}`
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Registry_Validate_CredHelpers(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name     string
+		registry *Registry
+		failure  bool
+	}{
+		{
+			name: "cred helper for registry",
+			registry: &Registry{
+				Name:        "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+				CredHelpers: map[string]string{"123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"},
+			},
+			failure: false,
+		},
+		{
+			name: "creds store",
+			registry: &Registry{
+				Name:       "gcr.io",
+				CredsStore: "gcr",
+			},
+			failure: false,
+		},
+		{
+			name: "cred helper for other registry",
+			registry: &Registry{
+				Name:        "index.docker.io",
+				CredHelpers: map[string]string{"gcr.io": "gcr"},
+			},
+			failure: true,
+		},
+		{
+			name: "cred helper without helper",
+			registry: &Registry{
+				Name:        "gcr.io",
+				CredsStore:  "gcr",
+				CredHelpers: map[string]string{"gcr.io": ""},
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.registry.Validate()
+
+			if test.failure {
+				if err == nil {
+					t.Errorf("Validate should have returned err")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Validate returned err: %v", err)
+			}
+		})
+	}
+}
+
+func TestDocker_Registry_Write_CredHelpers(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Name:        "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+		CredHelpers: map[string]string{"123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"},
+		CredsStore:  "acr-env",
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, "/kaniko/.docker/config.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := `{
+  "credHelpers": {
+    "123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"
+  },
+  "credsStore": "acr-env"
+}`
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Registry_Write_ExistingConfig(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	t.Setenv("DOCKER_CONFIG", "/root/.docker")
+
+	existing := `{
+  "auths": {
+    "index.docker.io": { "auth": "b2xkOm9sZA==" },
+    "quay.io": { "auth": "cXVheTpxdWF5" }
+  },
+  "credHelpers": { "gcr.io": "gcr" },
+  "proxies": { "default": { "httpProxy": "http://proxy.example.com:3128" } }
+}`
+
+	err := afero.WriteFile(appFS, "/root/.docker/config.json", []byte(existing), 0600)
+	if err != nil {
+		t.Errorf("WriteFile returned err: %v", err)
+	}
+
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+	}
+
+	err = r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, "/root/.docker/config.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := `{
+  "auths": {
+    "https://index.docker.io/v1/": {
+      "auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"
+    },
+    "quay.io": {
+      "auth": "cXVheTpxdWF5"
+    }
+  },
+  "credHelpers": {
+    "gcr.io": "gcr"
+  },
+  "proxies": {
+    "default": {
+      "httpProxy": "http://proxy.example.com:3128"
+    }
+  }
+}`
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Registry_Write_InvalidExistingConfig(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	err := afero.WriteFile(appFS, "/kaniko/.docker/config.json", []byte("{"), 0600)
+	if err != nil {
+		t.Errorf("WriteFile returned err: %v", err)
+	}
+
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+	}
+
+	err = r.Write()
+	if err == nil {
+		t.Errorf("Write should have returned err")
+	}
+}
+
+func TestDocker_Registry_Validate_Tokens(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name     string
+		registry *Registry
+	}{
+		{
+			name: "identity token",
+			registry: &Registry{
+				Name:          "index.docker.io",
+				Username:      "octocat",
+				IdentityToken: "superSecretToken",
+			},
+		},
+		{
+			name: "registry token",
+			registry: &Registry{
+				Name:          "index.docker.io",
+				RegistryToken: "superSecretToken",
+			},
+		},
+		{
+			name: "credentials registry token",
+			registry: &Registry{
+				Name:     "index.docker.io",
+				Username: "octocat",
+				Password: "superSecretPassword",
+				Credentials: []*Credential{
+					{Registry: "harbor.example.com", RegistryToken: "superSecretToken"},
+				},
+			},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.registry.Validate()
+			if err != nil {
+				t.Errorf("Validate returned err: %v", err)
+			}
+		})
+	}
+}
+
+func TestDocker_Registry_Write_Tokens(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Name:          "index.docker.io",
+		Username:      "octocat",
+		IdentityToken: "superSecretRefreshToken",
+		Credentials: []*Credential{
+			{Registry: "harbor.example.com", RegistryToken: "superSecretBearerToken"},
+		},
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, "/kaniko/.docker/config.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := `{
+  "auths": {
+    "harbor.example.com": {
+      "registrytoken": "superSecretBearerToken"
+    },
+    "https://index.docker.io/v1/": {
+      "auth": "b2N0b2NhdDo=",
+      "identitytoken": "superSecretRefreshToken"
+    }
+  }
+}`
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Registry_Validate_Mirrors(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name     string
+		registry *Registry
+		failure  bool
+	}{
+		{
+			name: "mirrors",
+			registry: &Registry{
+				Name:           "index.docker.io",
+				Username:       "octocat",
+				Password:       "superSecretPassword",
+				Mirrors:        []string{"mirror.example.com", "localhost:5000", "10.0.0.1:5000"},
+				MirrorUsername: "octocat",
+				MirrorPassword: "superSecretPassword",
+			},
+			failure: false,
+		},
+		{
+			name: "mirror token",
+			registry: &Registry{
+				Name:        "index.docker.io",
+				Username:    "octocat",
+				Password:    "superSecretPassword",
+				Mirrors:     []string{"mirror.example.com"},
+				MirrorToken: "superSecretToken",
+			},
+			failure: false,
+		},
+		{
+			name: "invalid mirror",
+			registry: &Registry{
+				Name:     "index.docker.io",
+				Username: "octocat",
+				Password: "superSecretPassword",
+				Mirrors:  []string{"https://mirror.example.com/v2"},
+			},
+			failure: true,
+		},
+		{
+			name: "mirror credentials without mirror",
+			registry: &Registry{
+				Name:           "index.docker.io",
+				Username:       "octocat",
+				Password:       "superSecretPassword",
+				MirrorUsername: "octocat",
+				MirrorPassword: "superSecretPassword",
+			},
+			failure: true,
+		},
+		{
+			name: "mirror username without password",
+			registry: &Registry{
+				Name:           "index.docker.io",
+				Username:       "octocat",
+				Password:       "superSecretPassword",
+				Mirrors:        []string{"mirror.example.com"},
+				MirrorUsername: "octocat",
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.registry.Validate()
+
+			if test.failure {
+				if err == nil {
+					t.Errorf("Validate should have returned err")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Validate returned err: %v", err)
+			}
+		})
+	}
+}
+
+func TestDocker_Registry_Write_Mirrors(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Name:           "index.docker.io",
+		Username:       "octocat",
+		Password:       "superSecretPassword",
+		Mirrors:        []string{"mirror.example.com"},
+		MirrorUsername: "octokitty",
+		MirrorPassword: "superSecretPassword",
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, "/kaniko/.docker/config.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := `{
+  "auths": {
+    "https://index.docker.io/v1/": {
+      "auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"
+    },
+    "mirror.example.com": {
+      "auth": "b2N0b2tpdHR5OnN1cGVyU2VjcmV0UGFzc3dvcmQ="
+    }
+  }
+}`
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Registry_Validate_DockerConfig(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Name:         "index.docker.io",
+		DockerConfig: `{"auths": {"index.docker.io": {"auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"}}}`,
+	}
+
+	err := r.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+}
+
+func TestDocker_Registry_Validate_InvalidDockerConfig(t *testing.T) {
+	// setup types
+	r := &Registry{
+		Name:         "index.docker.io",
+		Username:     "octocat",
+		Password:     "superSecretPassword",
+		DockerConfig: `{"auths": {"index.docker.io": {"auth": "b2N0b2NhdA"}}}`,
+	}
+
+	err := r.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestDocker_Registry_Write_DockerConfig(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+		DockerConfig: `{
+  "auths": {
+    "index.docker.io": { "auth": "b2xkOm9sZA==" },
+    "quay.io": { "auth": "cXVheTpxdWF5" }
+  }
+}`,
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, "/kaniko/.docker/config.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := `{
+  "auths": {
+    "https://index.docker.io/v1/": {
+      "auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"
+    },
+    "quay.io": {
+      "auth": "cXVheTpxdWF5"
+    }
+  }
+}`
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Registry_Write_DockerConfig_Proxies(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+		DockerConfig: `{
+  "auths": {
+    "quay.io": { "auth": "cXVheTpxdWF5" }
+  },
+  "proxies": {
+    "default": { "httpProxy": "http://proxy.example.com:3128", "noProxy": "localhost" }
+  }
+}`,
+	}
+
+	err := r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	got, err := afero.ReadFile(appFS, "/kaniko/.docker/config.json")
+	if err != nil {
+		t.Errorf("ReadFile returned err: %v", err)
+	}
+
+	want := `{
+  "auths": {
+    "https://index.docker.io/v1/": {
+      "auth": "b2N0b2NhdDpzdXBlclNlY3JldFBhc3N3b3Jk"
+    },
+    "quay.io": {
+      "auth": "cXVheTpxdWF5"
+    }
+  },
+  "proxies": {
+    "default": {
+      "httpProxy": "http://proxy.example.com:3128",
+      "noProxy": "localhost"
+    }
+  }
+}`
+
+	if string(got) != want {
+		t.Errorf("Write is %s, want %s", got, want)
+	}
+}
+
+func TestDocker_Registry_Write_Permissions(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	err := afero.WriteFile(appFS, "/kaniko/.docker/config.json", []byte(`{"proxies": {}}`), 0644)
+	if err != nil {
+		t.Errorf("WriteFile returned err: %v", err)
+	}
+
+	// setup types
+	r := &Registry{
+		Name:     "index.docker.io",
+		Username: "octocat",
+		Password: "superSecretPassword",
+	}
+
+	err = r.Write()
+	if err != nil {
+		t.Errorf("Write returned err: %v", err)
+	}
+
+	info, err := appFS.Stat("/kaniko/.docker/config.json")
+	if err != nil {
+		t.Errorf("Stat returned err: %v", err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Write mode is %v, want %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+
+	// verify the temporary file is not left behind
+	files, err := afero.ReadDir(appFS, "/kaniko/.docker")
+	if err != nil {
+		t.Errorf("ReadDir returned err: %v", err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("Write left %d files, want 1", len(files))
+	}
+}
@@ -3,11 +3,28 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+const (
+	// immutableFail defines the action to fail the build
+	// when an immutable tag is already published.
+	immutableFail = "fail"
+
+	// immutableSkip defines the action to skip publishing
+	// an immutable tag that is already published.
+	immutableSkip = "skip"
 )
 
 type (
@@ -15,6 +32,14 @@ type (
 	Repo struct {
 		// enable tagging of image via commit or tag
 		AutoTag bool
+		// enable tagging of image via branch for push events
+		AutoTagBranch bool
+		// template for the tag of the image for pull_request events
+		PullRequestTag string
+		// template for the tag of the image for deployment events
+		DeploymentTag string
+		// template for the tag of the image for schedule events
+		ScheduleTag string
 		// enable caching of image layers
 		Cache bool
 		// enable caching of image layers for a specific repo
@@ -33,8 +58,32 @@ type (
 		Name string
 		// tags of the image for the repository
 		Tags []string
+		// path to a file with additional tags of the image for the repository
+		TagsFile string
 		// a filter for topics
 		TopicsFilter string
+		// Go time layout for the calendar version tag of the image
+		CalverLayout string
+		// timezone for the calendar version tag of the image
+		CalverTimezone string
+		// enable adding the build number to the calendar version tag of the image
+		CalverBuildNumber bool
+		// enable rewriting invalid tags into valid docker tags
+		SanitizeTags bool
+		// regular expression for tags that must not be overwritten
+		ImmutableTags string
+		// action for an immutable tag that is already published - options (fail|skip)
+		ImmutableTagsAction string
+		// prefix added to each of the tags of the image
+		TagPrefix string
+		// suffix added to each of the tags of the image
+		TagSuffix string
+		// enable expanding a semantic version build tag into major, minor and patch tags
+		SemverTags bool
+		// enable adding the latest tag for a semantic version build tag
+		SemverLatest bool
+		// semantic version parsed from the build tag
+		version *semver.Version
 	}
 
 	// Label represents the open image specification fields.
@@ -103,18 +152,18 @@ func (r *Repo) AddLabels() []string {
 	// labels we will return
 	labels := []string{}
 
-	// append the standard set of labels
-	for k, v := range labelMap {
-		labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+	// append the standard set of labels, sorted by key
+	for _, k := range slices.Sorted(maps.Keys(labelMap)) {
+		labels = append(labels, fmt.Sprintf("%s=%s", k, labelMap[k]))
 	}
 
 	// append the custom set of labels
 	for _, label := range r.Label.CustomSet {
-		parsed := strings.Split(label, "=")
+		key, _, _ := strings.Cut(label, "=")
 
 		// do not let user overwrite predefined labels
-		if _, exists := labelMap[parsed[0]]; exists {
-			logrus.Fatalf("custom label %s already exists in predefined labels", parsed[0])
+		if _, exists := labelMap[key]; exists {
+			logrus.Fatalf("custom label %s already exists in predefined labels", key)
 		}
 
 		labels = append(labels, label)
@@ -124,16 +173,266 @@ func (r *Repo) AddLabels() []string {
 }
 
 // ConfigureAutoTagBuildTags adds the build tag to repo tags.
-func (r *Repo) ConfigureAutoTagBuildTags(b *Build) {
+func (r *Repo) ConfigureAutoTagBuildTags(b *Build) error {
+	// check if calendar version tags are enabled
+	if len(r.CalverLayout) > 0 {
+		tag, err := r.calverTag()
+		if err != nil {
+			return err
+		}
+
+		// add calendar version tag to list of repo tags
+		r.Tags = append(r.Tags, tag)
+	}
+
 	// check what build event was provided
 	switch b.Event {
 	case "tag":
+		// check if semantic version tags are enabled
+		if r.SemverTags {
+			// add semantic version tags to list of repo tags, skipping a
+			// latest tag that is already provided, which is only removed
+			// when a newer version is published
+			for _, tag := range r.semverTags(b.Tag) {
+				if !slices.Contains(r.Tags, tag) {
+					r.Tags = append(r.Tags, tag)
+				}
+			}
+
+			return nil
+		}
+
 		// add build tag to list of repo tags
 		r.Tags = append(r.Tags, b.Tag)
+	case "push":
+		// add build sha to list of repo tags
+		r.Tags = append(r.Tags, b.Sha)
+
+		// check if branch tags are enabled
+		if r.AutoTagBranch {
+			r.configureBranchTags(b)
+		}
+	case "pull_request":
+		// add build sha and pull request tag to list of repo tags
+		r.Tags = append(r.Tags, b.Sha)
+		r.addEventTag(r.PullRequestTag)
+	case "deployment":
+		// add build sha and deployment tag to list of repo tags
+		r.Tags = append(r.Tags, b.Sha)
+		r.addEventTag(r.DeploymentTag)
+	case "schedule":
+		// add build sha and schedule tag to list of repo tags
+		r.Tags = append(r.Tags, b.Sha)
+		r.addEventTag(r.ScheduleTag)
 	default:
 		// add build sha to list of repo tags
 		r.Tags = append(r.Tags, b.Sha)
 	}
+
+	return nil
+}
+
+// calverTag creates the calendar version tag, such as 2024.02.03, from the
+// timestamp when the image was built, which is the same timestamp used for
+// the org.opencontainers.image.created label.
+func (r *Repo) calverTag() (string, error) {
+	created, err := time.Parse(time.RFC3339, r.Label.Created)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse build timestamp %s: %w", r.Label.Created, err)
+	}
+
+	// check if a timezone is provided for the tag
+	if len(r.CalverTimezone) > 0 {
+		location, err := time.LoadLocation(r.CalverTimezone)
+		if err != nil {
+			return "", fmt.Errorf("invalid calver timezone %s: %w", r.CalverTimezone, err)
+		}
+
+		created = created.In(location)
+	}
+
+	tag := created.Format(r.CalverLayout)
+
+	// check if the build number should be added to the tag
+	if r.CalverBuildNumber {
+		tag = fmt.Sprintf("%s-%d", tag, r.Label.Number)
+	}
+
+	return tag, nil
+}
+
+// addEventTag adds the tag for the build event to repo tags when it is provided.
+//
+// The tag is a template, which is rendered before the tags are validated.
+func (r *Repo) addEventTag(tag string) {
+	if len(tag) > 0 {
+		r.Tags = append(r.Tags, tag)
+	}
+}
+
+// configureBranchTags adds the sanitized build branch to repo tags, and
+// removes the latest tag when the build is not for the default branch.
+func (r *Repo) configureBranchTags(b *Build) {
+	// check if the branch is provided
+	if len(b.Branch) == 0 {
+		logrus.Warn("no build branch provided, skipping branch tag")
+
+		return
+	}
+
+	// leave room for the tag prefix and suffix
+	tag := sanitizeTag(b.Branch, maxTagLength-len(r.TagPrefix)-len(r.TagSuffix))
+	if len(tag) == 0 {
+		logrus.Warnf("branch %s has no valid tag characters, skipping branch tag", b.Branch)
+	} else {
+		// add build branch to list of repo tags
+		r.Tags = append(r.Tags, tag)
+	}
+
+	// check if the build is for the default branch
+	if len(b.DefaultBranch) > 0 && b.Branch != b.DefaultBranch {
+		logrus.Debugf("branch %s is not the default branch %s, removing latest tag", b.Branch, b.DefaultBranch)
+
+		r.Tags = slices.DeleteFunc(r.Tags, func(tag string) bool {
+			return tag == "latest"
+		})
+	}
+}
+
+// Sanitize rewrites each of the repo tags into a valid docker tag
+// when sanitizing tags is enabled, instead of rejecting them.
+func (r *Repo) Sanitize() error {
+	// check if tags should be rewritten
+	if !r.SanitizeTags {
+		return nil
+	}
+
+	tags, err := r.sanitizeTags(r.Tags)
+	if err != nil {
+		return err
+	}
+
+	r.Tags = tags
+
+	return nil
+}
+
+// sanitizeTags returns each of the tags rewritten into a valid docker tag.
+func (r *Repo) sanitizeTags(tags []string) ([]string, error) {
+	// leave room for the tag prefix and suffix
+	length := maxTagLength - len(r.TagPrefix) - len(r.TagSuffix)
+
+	sanitized := []string{}
+
+	for _, tag := range tags {
+		s := sanitizeTag(tag, length)
+
+		// verify the tag has any valid characters
+		if len(s) == 0 {
+			return nil, fmt.Errorf("tag '%s' is empty after sanitizing", tag)
+		}
+
+		if s != tag {
+			logrus.Infof("sanitized tag %s to %s", tag, s)
+		}
+
+		sanitized = append(sanitized, s)
+	}
+
+	return sanitized, nil
+}
+
+// sanitizeTag rewrites a tag into a valid docker tag by replacing all
+// characters not allowed with a hyphen, lowercasing and removing any
+// leading periods and hyphens. A tag longer than the length is truncated
+// with a hash of the original tag appended, so truncated tags stay unique.
+func sanitizeTag(tag string, length int) string {
+	sanitized := strings.ToLower(invalidTagRegexp.ReplaceAllString(tag, "-"))
+	sanitized = strings.TrimLeft(sanitized, ".-")
+
+	// check if the tag is longer than allowed
+	if len(sanitized) > length {
+		sum := sha256.Sum256([]byte(tag))
+		hash := hex.EncodeToString(sum[:])[:tagHashLength]
+
+		// check if there is no room for the tag, since a tag can not start
+		// with a hyphen, so the hash is used as much as there is room for
+		if length <= len(hash)+1 {
+			return hash[:min(max(length, 0), len(hash))]
+		}
+
+		sanitized = sanitized[:length-len(hash)-1] + "-" + hash
+	}
+
+	return sanitized
+}
+
+// ReadTagsFile adds the comma or newline separated tags from the tags file to repo tags.
+func (r *Repo) ReadTagsFile() error {
+	logrus.Debugf("reading tags from file %s", r.TagsFile)
+
+	// use custom filesystem which enables us to test
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	data, err := a.ReadFile(r.TagsFile)
+	if err != nil {
+		return fmt.Errorf("unable to read tags file %s: %w", r.TagsFile, err)
+	}
+
+	tags := strings.FieldsFunc(string(data), func(c rune) bool {
+		return c == ',' || c == '\n'
+	})
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+
+		// skip empty tags, such as from a trailing newline
+		if len(tag) == 0 {
+			continue
+		}
+
+		// add tag from file to list of repo tags
+		r.Tags = append(r.Tags, tag)
+	}
+
+	return nil
+}
+
+// semverTags expands a semantic version tag, such as v1.2.3, into the
+// tags 1, 1.2 and 1.2.3 and optionally latest. A prerelease version
+// only receives the full version tag.
+func (r *Repo) semverTags(tag string) []string {
+	v, err := semver.StrictNewVersion(strings.TrimPrefix(tag, "v"))
+	if err != nil {
+		logrus.Warnf("build tag %s is not a semantic version, using it as-is", tag)
+
+		return []string{tag}
+	}
+
+	r.version = v
+
+	// build metadata is not allowed in docker tags, so it is excluded
+	full := fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch())
+
+	// check if the version is a prerelease
+	if len(v.Prerelease()) > 0 {
+		return []string{fmt.Sprintf("%s-%s", full, v.Prerelease())}
+	}
+
+	tags := []string{
+		fmt.Sprintf("%d", v.Major()),
+		fmt.Sprintf("%d.%d", v.Major(), v.Minor()),
+		full,
+	}
+
+	// check if the latest tag is enabled
+	if r.SemverLatest {
+		tags = append(tags, "latest")
+	}
+
+	return tags
 }
 
 // Validate verifies the Repo is properly configured.
@@ -153,6 +452,21 @@ func (r *Repo) Validate() error {
 		return fmt.Errorf("no repo name provided")
 	}
 
+	// verify repo is a valid repository name
+	_, err := parseRepo(r.Name)
+	if err != nil {
+		return err
+	}
+
+	// check if cache name is provided
+	if len(r.CacheName) > 0 {
+		// verify cache repo is a valid repository name
+		_, err = parseRepo(r.CacheName)
+		if err != nil {
+			return err
+		}
+	}
+
 	// check if auto tagging is disabled
 	if !r.AutoTag {
 		// verify tags are provided
@@ -161,10 +475,15 @@ func (r *Repo) Validate() error {
 		}
 	}
 
+	// verify the tag prefix and suffix are valid in a docker tag
+	if !tagPrefixRegexp.MatchString(r.TagPrefix) || !tagSuffixRegexp.MatchString(r.TagSuffix) {
+		return fmt.Errorf("tag prefix '%s' or suffix '%s' not allowed", r.TagPrefix, r.TagSuffix)
+	}
+
 	// check if tags are provided
 	if len(r.Tags) > 0 {
-		// check each tag value for valid docker tag syntax
-		for _, tag := range r.Tags {
+		// check each destination tag for valid docker tag syntax
+		for _, tag := range r.DestinationTags() {
 			if !tagRegexp.MatchString(tag) {
 				return fmt.Errorf(errTagValidation, tag)
 			}
@@ -173,12 +492,25 @@ func (r *Repo) Validate() error {
 
 	// check validity of regex expression for topics
 	if len(r.TopicsFilter) > 0 {
-		_, err := regexp.Compile(r.TopicsFilter)
+		_, err = regexp.Compile(r.TopicsFilter)
 		if err != nil {
 			return fmt.Errorf("topics filter regex not valid")
 		}
 	}
 
+	// check validity of regex expression for immutable tags
+	if len(r.ImmutableTags) > 0 {
+		_, err = regexp.Compile(r.ImmutableTags)
+		if err != nil {
+			return fmt.Errorf("immutable tags regex not valid: %w", err)
+		}
+
+		// make sure a valid action was provided for immutable tags
+		if r.ImmutableTagsAction != immutableFail && r.ImmutableTagsAction != immutableSkip {
+			return fmt.Errorf("immutable tags action must be one of '%s' or '%s'", immutableFail, immutableSkip)
+		}
+	}
+
 	// make sure a valid compression type was provided, if any
 	if len(r.Compression) > 0 {
 		if r.Compression != "gzip" && r.Compression != "zstd" {
@@ -195,9 +527,9 @@ func (r *Repo) Validate() error {
 
 	if len(r.Label.CustomSet) > 0 {
 		for _, label := range r.Label.CustomSet {
-			split := strings.Split(label, "=")
+			key, _, ok := strings.Cut(label, "=")
 
-			if len(split) != 2 {
+			if !ok || len(key) == 0 {
 				return fmt.Errorf("custom label %s is not in the format key=value", label)
 			}
 		}
@@ -205,3 +537,58 @@ func (r *Repo) Validate() error {
 
 	return nil
 }
+
+// DestinationTags returns the tags the image is published with, which are
+// the repo tags with the tag prefix and suffix added and duplicates removed.
+func (r *Repo) DestinationTags() []string {
+	tags := []string{}
+
+	for _, tag := range r.Tags {
+		tag = r.TagPrefix + tag + r.TagSuffix
+
+		// only keep the first occurrence of each tag
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// removeTag removes the repo tags that are published as the destination tag.
+func (r *Repo) removeTag(destination string) {
+	r.Tags = slices.DeleteFunc(r.Tags, func(tag string) bool {
+		return r.TagPrefix+tag+r.TagSuffix == destination
+	})
+}
+
+// clone returns a copy of the Repo, so the tags and labels of
+// the copy can be changed without modifying the original.
+func (r *Repo) clone() *Repo {
+	repo := *r
+
+	repo.Tags = slices.Clone(r.Tags)
+	repo.Labels = slices.Clone(r.Labels)
+
+	// check if the predefined labels are provided
+	if r.Label != nil {
+		label := *r.Label
+
+		label.Topics = slices.Clone(r.Label.Topics)
+		label.CustomSet = slices.Clone(r.Label.CustomSet)
+
+		repo.Label = &label
+	}
+
+	return &repo
+}
+
+// CacheRepo returns the name of the repository for caching image layers.
+func (r *Repo) CacheRepo() string {
+	// check if repo cache name is provided
+	if len(r.CacheName) > 0 {
+		return r.CacheName
+	}
+
+	return r.Name
+}
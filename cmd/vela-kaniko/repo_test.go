@@ -2,7 +2,13 @@
 
 package main
 
-import "testing"
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
 
 func TestDocker_Repo_Validate(t *testing.T) {
 	// setup types
@@ -237,3 +243,601 @@ func TestDocker_Repo_CompressionLevelTooLow(t *testing.T) {
 		t.Errorf("Validate should have returned err")
 	}
 }
+
+func TestDocker_Repo_Validate_InvalidName(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name      string
+		repo      string
+		cacheRepo string
+	}{
+		{name: "uppercase", repo: "index.docker.io/Target/vela-kaniko"},
+		{name: "tag", repo: "index.docker.io/target/vela-kaniko:latest"},
+		{name: "scheme", repo: "https://index.docker.io/target/vela-kaniko"},
+		{name: "cache repo", repo: "index.docker.io/target/vela-kaniko", cacheRepo: "index.docker.io/target/vela kaniko"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Repo{
+				Cache:     true,
+				CacheName: test.cacheRepo,
+				Name:      test.repo,
+				Tags:      []string{"latest"},
+				Label:     &Label{},
+			}
+
+			err := r.Validate()
+			if err == nil {
+				t.Errorf("Validate should have returned err")
+			}
+		})
+	}
+}
+
+func TestDocker_Repo_ConfigureAutoTagBuildTags_Semver(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name   string
+		tag    string
+		tags   []string
+		latest bool
+		want   []string
+	}{
+		{
+			name: "version",
+			tag:  "v1.2.3",
+			want: []string{"1", "1.2", "1.2.3"},
+		},
+		{
+			name:   "version with latest",
+			tag:    "1.2.3",
+			latest: true,
+			want:   []string{"1", "1.2", "1.2.3", "latest"},
+		},
+		{
+			name:   "version with provided latest",
+			tag:    "1.2.3",
+			tags:   []string{"latest"},
+			latest: true,
+			want:   []string{"latest", "1", "1.2", "1.2.3"},
+		},
+		{
+			name:   "prerelease",
+			tag:    "v1.2.3-rc.1",
+			latest: true,
+			want:   []string{"1.2.3-rc.1"},
+		},
+		{
+			name: "prerelease with provided latest",
+			tag:  "v1.2.3-rc.1",
+			tags: []string{"latest"},
+			want: []string{"latest", "1.2.3-rc.1"},
+		},
+		{
+			name: "build metadata",
+			tag:  "v1.2.3+abc123",
+			want: []string{"1", "1.2", "1.2.3"},
+		},
+		{
+			name:   "not a version",
+			tag:    "release-1",
+			latest: true,
+			want:   []string{"release-1"},
+		},
+		{
+			name: "not a version with provided latest",
+			tag:  "release-1",
+			tags: []string{"latest"},
+			want: []string{"latest", "release-1"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Repo{
+				AutoTag:      true,
+				SemverTags:   true,
+				SemverLatest: test.latest,
+				Tags:         test.tags,
+			}
+
+			err := r.ConfigureAutoTagBuildTags(&Build{
+				Event: "tag",
+				Tag:   test.tag,
+			})
+			if err != nil {
+				t.Errorf("ConfigureAutoTagBuildTags returned err: %v", err)
+			}
+
+			if !reflect.DeepEqual(r.Tags, test.want) {
+				t.Errorf("ConfigureAutoTagBuildTags is %v, want %v", r.Tags, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Repo_ConfigureAutoTagBuildTags_Branch(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name          string
+		event         string
+		branch        string
+		defaultBranch string
+		want          []string
+	}{
+		{
+			name:          "default branch",
+			event:         "push",
+			branch:        "main",
+			defaultBranch: "main",
+			want:          []string{"latest", "deadbeef", "main"},
+		},
+		{
+			name:          "feature branch",
+			event:         "push",
+			branch:        "feature/foo",
+			defaultBranch: "main",
+			want:          []string{"deadbeef", "feature-foo"},
+		},
+		{
+			name:          "branch with invalid characters",
+			event:         "push",
+			branch:        ".hotfix/#123@v2",
+			defaultBranch: "main",
+			want:          []string{"deadbeef", "hotfix-123-v2"},
+		},
+		{
+			name:          "long branch",
+			event:         "push",
+			branch:        "feature/" + strings.Repeat("a", 130),
+			defaultBranch: "main",
+			want:          []string{"deadbeef", sanitizeTag("feature/"+strings.Repeat("a", 130), maxTagLength)},
+		},
+		{
+			name:          "no default branch",
+			event:         "push",
+			branch:        "feature/foo",
+			defaultBranch: "",
+			want:          []string{"latest", "deadbeef", "feature-foo"},
+		},
+		{
+			name:          "pull request",
+			event:         "pull_request",
+			branch:        "feature/foo",
+			defaultBranch: "main",
+			want:          []string{"latest", "deadbeef"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Repo{
+				AutoTag:       true,
+				AutoTagBranch: true,
+				Tags:          []string{"latest"},
+			}
+
+			err := r.ConfigureAutoTagBuildTags(&Build{
+				Branch:        test.branch,
+				DefaultBranch: test.defaultBranch,
+				Event:         test.event,
+				Sha:           "deadbeef",
+			})
+			if err != nil {
+				t.Errorf("ConfigureAutoTagBuildTags returned err: %v", err)
+			}
+
+			if !reflect.DeepEqual(r.Tags, test.want) {
+				t.Errorf("ConfigureAutoTagBuildTags is %v, want %v", r.Tags, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Repo_ConfigureAutoTagBuildTags_Event(t *testing.T) {
+	// setup types
+	r := &Repo{
+		AutoTag:        true,
+		PullRequestTag: "pr-{{ .PullRequest }}",
+		DeploymentTag:  "deploy-{{ .Deployment }}",
+		ScheduleTag:    "",
+	}
+
+	// setup tests
+	tests := []struct {
+		event string
+		want  []string
+	}{
+		{
+			event: "pull_request",
+			want:  []string{"deadbeef", "pr-{{ .PullRequest }}"},
+		},
+		{
+			event: "deployment",
+			want:  []string{"deadbeef", "deploy-{{ .Deployment }}"},
+		},
+		{
+			event: "schedule",
+			want:  []string{"deadbeef"},
+		},
+		{
+			event: "comment",
+			want:  []string{"deadbeef"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		r.Tags = []string{}
+
+		err := r.ConfigureAutoTagBuildTags(&Build{
+			Event: test.event,
+			Sha:   "deadbeef",
+		})
+		if err != nil {
+			t.Errorf("ConfigureAutoTagBuildTags returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(r.Tags, test.want) {
+			t.Errorf("ConfigureAutoTagBuildTags for %s is %v, want %v", test.event, r.Tags, test.want)
+		}
+	}
+}
+
+func TestDocker_Repo_Sanitize(t *testing.T) {
+	long := strings.Repeat("a", 130)
+
+	// setup types
+	r := &Repo{
+		Name:         "index.docker.io/target/vela-kaniko",
+		SanitizeTags: true,
+		Tags:         []string{"latest", "feature/Foo", "-.refs/heads/main", long, long + "b"},
+		Label:        &Label{},
+	}
+
+	err := r.Sanitize()
+	if err != nil {
+		t.Errorf("Sanitize returned err: %v", err)
+	}
+
+	err = r.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	want := []string{"latest", "feature-foo", "refs-heads-main"}
+
+	if !reflect.DeepEqual(r.Tags[:3], want) {
+		t.Errorf("Sanitize tags are %v, want %v", r.Tags[:3], want)
+	}
+
+	// verify truncated tags are valid and unique
+	for _, tag := range r.Tags[3:] {
+		if len(tag) != 128 || !strings.HasPrefix(tag, strings.Repeat("a", 119)+"-") {
+			t.Errorf("Sanitize truncated tag is %s", tag)
+		}
+	}
+
+	if r.Tags[3] == r.Tags[4] {
+		t.Errorf("Sanitize truncated tags are not unique: %s", r.Tags[3])
+	}
+}
+
+func TestDocker_Repo_Sanitize_Empty(t *testing.T) {
+	// setup types
+	r := &Repo{
+		Name:         "index.docker.io/target/vela-kaniko",
+		SanitizeTags: true,
+		Tags:         []string{"latest", "-/."},
+		Label:        &Label{},
+	}
+
+	err := r.Sanitize()
+	if err == nil {
+		t.Errorf("Sanitize should have returned err")
+	}
+}
+
+func TestDocker_Repo_Validate_SanitizeTags(t *testing.T) {
+	// setup types
+	r := &Repo{
+		Name:         "index.docker.io/target/vela-kaniko",
+		SanitizeTags: true,
+		Tags:         []string{"feature/Foo"},
+		Label:        &Label{},
+	}
+
+	// verify the tags are only rewritten by Sanitize
+	err := r.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+
+	if !reflect.DeepEqual(r.Tags, []string{"feature/Foo"}) {
+		t.Errorf("Validate modified tags %v", r.Tags)
+	}
+}
+
+func TestDocker_Repo_Validate_TagPrefixAndSuffix(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		prefix  string
+		suffix  string
+		tags    []string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			prefix: "v2-",
+			suffix: "-alpine",
+			tags:   []string{"latest"},
+		},
+		{
+			name:    "invalid prefix",
+			prefix:  "-v2",
+			tags:    []string{"latest"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid suffix",
+			suffix:  "/alpine",
+			tags:    []string{"latest"},
+			wantErr: true,
+		},
+		{
+			name:    "too long",
+			suffix:  "-alpine",
+			tags:    []string{strings.Repeat("a", 125)},
+			wantErr: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Repo{
+				Name:      "index.docker.io/target/vela-kaniko",
+				Tags:      test.tags,
+				TagPrefix: test.prefix,
+				TagSuffix: test.suffix,
+				Label:     &Label{},
+			}
+
+			err := r.Validate()
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate returned err: %v, want err %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDocker_Repo_Sanitize_Suffix(t *testing.T) {
+	// setup types
+	r := &Repo{
+		Name:         "index.docker.io/target/vela-kaniko",
+		SanitizeTags: true,
+		Tags:         []string{strings.Repeat("a", 130)},
+		TagSuffix:    "-alpine",
+		Label:        &Label{},
+	}
+
+	err := r.Sanitize()
+	if err != nil {
+		t.Errorf("Sanitize returned err: %v", err)
+	}
+
+	err = r.Validate()
+	if err != nil {
+		t.Errorf("Validate returned err: %v", err)
+	}
+
+	if got := r.DestinationTags()[0]; len(got) != 128 || !strings.HasSuffix(got, "-alpine") {
+		t.Errorf("DestinationTags is %s, want 128 characters ending in -alpine", got)
+	}
+}
+
+func TestDocker_Repo_Validate_ImmutableTags(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		regex   string
+		action  string
+		wantErr bool
+	}{
+		{name: "valid", regex: `^\d+\.\d+\.\d+$`, action: "skip"},
+		{name: "invalid regex", regex: `^\d+(`, action: "fail", wantErr: true},
+		{name: "invalid action", regex: `^\d+$`, action: "overwrite", wantErr: true},
+		{name: "disabled", regex: "", action: ""},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Repo{
+				Name:                "index.docker.io/target/vela-kaniko",
+				Tags:                []string{"latest"},
+				ImmutableTags:       test.regex,
+				ImmutableTagsAction: test.action,
+				Label:               &Label{},
+			}
+
+			err := r.Validate()
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate returned err: %v, want err %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDocker_Repo_ConfigureAutoTagBuildTags_Calver(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name        string
+		layout      string
+		timezone    string
+		buildNumber bool
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:   "date",
+			layout: "2006.01.02",
+			want:   []string{"2026.10.16", "deadbeef"},
+		},
+		{
+			name:     "timestamp in timezone",
+			layout:   "20060102-1504",
+			timezone: "America/Chicago",
+			want:     []string{"20261016-0930", "deadbeef"},
+		},
+		{
+			name:        "date with build number",
+			layout:      "2006.01.02",
+			timezone:    "UTC",
+			buildNumber: true,
+			want:        []string{"2026.10.16-42", "deadbeef"},
+		},
+		{
+			name:     "invalid timezone",
+			layout:   "2006.01.02",
+			timezone: "Mars/Olympus_Mons",
+			wantErr:  true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Repo{
+				AutoTag:           true,
+				CalverLayout:      test.layout,
+				CalverTimezone:    test.timezone,
+				CalverBuildNumber: test.buildNumber,
+				Label: &Label{
+					Created: "2026-10-16T14:30:00Z",
+					Number:  42,
+				},
+			}
+
+			err := r.ConfigureAutoTagBuildTags(&Build{
+				Event: "schedule",
+				Sha:   "deadbeef",
+			})
+			if (err != nil) != test.wantErr {
+				t.Errorf("ConfigureAutoTagBuildTags returned err: %v, want err %v", err, test.wantErr)
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(r.Tags, test.want) {
+				t.Errorf("ConfigureAutoTagBuildTags is %v, want %v", r.Tags, test.want)
+			}
+		})
+	}
+}
+
+func TestDocker_Repo_ReadTagsFile(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	a := &afero.Afero{
+		Fs: appFS,
+	}
+
+	err := a.WriteFile("/vela/src/.tags", []byte("1.2.3, 1.2\n1\n\nrc-1,\n"), 0644)
+	if err != nil {
+		t.Errorf("unable to write tags file: %v", err)
+	}
+
+	// setup types
+	r := &Repo{
+		Tags:     []string{"latest"},
+		TagsFile: "/vela/src/.tags",
+	}
+
+	err = r.ReadTagsFile()
+	if err != nil {
+		t.Errorf("ReadTagsFile returned err: %v", err)
+	}
+
+	want := []string{"latest", "1.2.3", "1.2", "1", "rc-1"}
+
+	if !reflect.DeepEqual(r.Tags, want) {
+		t.Errorf("ReadTagsFile is %v, want %v", r.Tags, want)
+	}
+}
+
+func TestDocker_Repo_ReadTagsFile_Missing(t *testing.T) {
+	// setup filesystem
+	appFS = afero.NewMemMapFs()
+
+	// setup types
+	r := &Repo{
+		TagsFile: "/vela/src/.tags",
+	}
+
+	err := r.ReadTagsFile()
+	if err == nil {
+		t.Errorf("ReadTagsFile should have returned err")
+	}
+}
+
+func TestDocker_sanitizeTag(t *testing.T) {
+	long := strings.Repeat("a", 20)
+
+	// setup tests
+	tests := []struct {
+		name   string
+		length int
+	}{
+		{name: "room for tag", length: 12},
+		{name: "room for hash", length: 9},
+		{name: "room for part of hash", length: 4},
+		{name: "no room", length: -3},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := sanitizeTag(long, test.length)
+
+			if len(got) > max(test.length, 0) {
+				t.Errorf("sanitizeTag is %s, want at most %d characters", got, test.length)
+			}
+
+			if len(got) > 0 && !tagRegexp.MatchString(got) {
+				t.Errorf("sanitizeTag is %s, want a valid tag", got)
+			}
+		})
+	}
+}
+
+func TestDocker_Repo_clone(t *testing.T) {
+	// setup types
+	r := &Repo{
+		Name:   "index.docker.io/octocat/hello-world",
+		Tags:   []string{"latest"},
+		Labels: []string{"foo=bar"},
+		Label:  testLabel(),
+	}
+
+	// run test
+	got := r.clone()
+
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("clone is %+v, want %+v", got, r)
+	}
+
+	got.Tags[0] = "1.2.3"
+	got.Labels[0] = "foo=baz"
+	got.Label.Topics[0] = "id456"
+	got.Label.Number = 2
+
+	// verify the original is not modified
+	if r.Tags[0] != "latest" || r.Labels[0] != "foo=bar" || r.Label.Topics[0] != "id123" || r.Label.Number != 1 {
+		t.Errorf("clone modified repo %+v with label %+v", r, r.Label)
+	}
+}
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// specSucceeded defines the result of a build spec that was built.
+	specSucceeded = "succeeded"
+
+	// specFailed defines the result of a build spec that failed to build.
+	specFailed = "failed"
+
+	// specSkipped defines the result of a build spec that was not built
+	// since an earlier build failed.
+	specSkipped = "skipped"
+)
+
+type (
+	// Spec represents the plugin configuration for one
+	// of several images built in the same step.
+	//
+	// Any field that is not provided is taken from the
+	// image and repo configuration for the step.
+	Spec struct {
+		// path to the context for building the image
+		Context string `json:"context"`
+		// path to the file for building the image
+		Dockerfile string `json:"dockerfile"`
+		// build stage to target for image
+		Target string `json:"target"`
+		// name of the repository for the image
+		Repo string `json:"repo"`
+		// tags of the image for the repository, which
+		// replace the repo tags, including automatic tags
+		Tags []string `json:"tags"`
+		// variables passed to the image at build-time
+		BuildArgs map[string]string `json:"build_args"`
+	}
+
+	// specResult represents the result of building the image for a build spec.
+	specResult struct {
+		// name of the repository for the image
+		Repo string
+		// path to the file for building the image
+		Dockerfile string
+		// outcome of the build - options (succeeded|failed|skipped)
+		Status string
+		// error returned from the build
+		Err error
+	}
+)
+
+// args returns the build-time variables for the build spec, sorted by key.
+func (s *Spec) args() []string {
+	args := []string{}
+
+	for _, key := range slices.Sorted(maps.Keys(s.BuildArgs)) {
+		args = append(args, fmt.Sprintf("%s=%s", key, s.BuildArgs[key]))
+	}
+
+	return args
+}
+
+// forSpec returns a copy of the plugin configured
+// to build the image for the build spec.
+func (p *Plugin) forSpec(s *Spec) *Plugin {
+	build := *p.Build
+	image := *p.Image
+	repo := p.Repo.clone()
+
+	// clean up the filesystem after the build, so the next build starts fresh
+	build.Cleanup = true
+
+	image.Builds = nil
+
+	// build args from the spec are added last, so they take precedence
+	image.Args = append(slices.Clone(p.Image.Args), s.args()...)
+
+	if len(s.Context) > 0 {
+		image.Context = s.Context
+	}
+
+	if len(s.Dockerfile) > 0 {
+		image.Dockerfile = s.Dockerfile
+	}
+
+	if len(s.Target) > 0 {
+		image.Target = s.Target
+	}
+
+	if len(s.Repo) > 0 {
+		repo.Name = s.Repo
+	}
+
+	if len(s.Tags) > 0 {
+		repo.Tags = slices.Clone(s.Tags)
+	}
+
+	return &Plugin{
+		Build:    &build,
+		Image:    &image,
+		Registry: p.Registry,
+		Repo:     repo,
+	}
+}
+
+// execSpecs runs the kaniko build for each build spec one after another
+// and reports the result for each image.
+func (p *Plugin) execSpecs(ctx context.Context) error {
+	results := []*specResult{}
+
+	var failed bool
+
+	for i, s := range p.Image.Builds {
+		sp := p.forSpec(s)
+
+		result := &specResult{
+			Repo:       sp.Repo.Name,
+			Dockerfile: sp.Image.Dockerfile,
+			Status:     specSucceeded,
+		}
+
+		results = append(results, result)
+
+		// check if the remaining builds should be skipped after a failure
+		if failed && !p.Image.ContinueOnError {
+			result.Status = specSkipped
+
+			continue
+		}
+
+		logrus.Infof("building image %d of %d for %s from %s", i+1, len(p.Image.Builds), result.Repo, result.Dockerfile)
+
+		err := sp.build(ctx)
+		if err != nil {
+			logrus.Errorf("unable to build image for %s from %s: %v", result.Repo, result.Dockerfile, err)
+
+			result.Status = specFailed
+			result.Err = err
+
+			failed = true
+		}
+	}
+
+	return reportSpecs(results)
+}
+
+// reportSpecs outputs the result of each build spec and returns an error for those that failed.
+func reportSpecs(results []*specResult) error {
+	var errs []error
+
+	logrus.Info("results for images built in step:")
+
+	for _, result := range results {
+		logrus.Infof("  %s from %s: %s", result.Repo, result.Dockerfile, result.Status)
+
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("unable to build image for %s from %s: %w", result.Repo, result.Dockerfile, result.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
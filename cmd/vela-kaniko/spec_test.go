@@ -0,0 +1,325 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestDocker_Spec_args(t *testing.T) {
+	// setup types
+	s := &Spec{
+		BuildArgs: map[string]string{
+			"VERSION":  "1.2.3",
+			"CHECKSUM": "a,b=c",
+		},
+	}
+
+	want := []string{"CHECKSUM=a,b=c", "VERSION=1.2.3"}
+
+	got := s.args()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("args is %v, want %v", got, want)
+	}
+}
+
+func TestDocker_Plugin_forSpec(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			Event: "push",
+			Sha:   "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+		},
+		Image: &Image{
+			Args:       []string{"foo=bar"},
+			Context:    ".",
+			Dockerfile: "Dockerfile",
+			Target:     "release",
+			Builds: []*Spec{
+				{
+					Context:    "api",
+					Dockerfile: "api/Dockerfile",
+					Repo:       "index.docker.io/octocat/api",
+					Tags:       []string{"api"},
+					BuildArgs:  map[string]string{"foo": "baz"},
+				},
+				{
+					Target: "debug",
+				},
+			},
+		},
+		Registry: &Registry{
+			Name: "index.docker.io",
+		},
+		Repo: &Repo{
+			Name:   "index.docker.io/octocat/hello-world",
+			Tags:   []string{"latest"},
+			Labels: []string{"foo=bar"},
+			Label:  testLabel(),
+		},
+	}
+
+	// run test
+	api := p.forSpec(p.Image.Builds[0])
+
+	if !api.Build.Cleanup || p.Build.Cleanup {
+		t.Errorf("forSpec cleanup is %v, want true for the spec only", api.Build.Cleanup)
+	}
+
+	if api.Image.Context != "api" || api.Image.Dockerfile != "api/Dockerfile" || api.Image.Target != "release" {
+		t.Errorf("forSpec image is %+v", api.Image)
+	}
+
+	if !reflect.DeepEqual(api.Image.Args, []string{"foo=bar", "foo=baz"}) {
+		t.Errorf("forSpec args is %v, want %v", api.Image.Args, []string{"foo=bar", "foo=baz"})
+	}
+
+	if api.Repo.Name != "index.docker.io/octocat/api" || !reflect.DeepEqual(api.Repo.Tags, []string{"api"}) {
+		t.Errorf("forSpec repo is %s with tags %v", api.Repo.Name, api.Repo.Tags)
+	}
+
+	if len(api.Image.Builds) != 0 {
+		t.Errorf("forSpec builds is %v, want none", api.Image.Builds)
+	}
+
+	cmd := api.Command(t.Context())
+
+	if !slices.Contains(cmd.Args, "--cleanup") {
+		t.Errorf("Command is %v, want --cleanup", cmd.Args)
+	}
+
+	// verify the step configuration is inherited
+	debug := p.forSpec(p.Image.Builds[1])
+
+	if debug.Image.Context != "." || debug.Image.Dockerfile != "Dockerfile" || debug.Image.Target != "debug" {
+		t.Errorf("forSpec image is %+v", debug.Image)
+	}
+
+	if debug.Repo.Name != p.Repo.Name || !reflect.DeepEqual(debug.Repo.Tags, p.Repo.Tags) {
+		t.Errorf("forSpec repo is %s with tags %v", debug.Repo.Name, debug.Repo.Tags)
+	}
+
+	// verify the labels of the step are not modified
+	if !reflect.DeepEqual(p.Repo.Labels, []string{"foo=bar"}) {
+		t.Errorf("Repo labels is %v, want %v", p.Repo.Labels, []string{"foo=bar"})
+	}
+
+	if debug.Repo.Label == p.Repo.Label || !reflect.DeepEqual(debug.Repo.Label, p.Repo.Label) {
+		t.Errorf("forSpec label is %v, want a copy of %v", debug.Repo.Label, p.Repo.Label)
+	}
+}
+
+func TestDocker_Plugin_Validate_Specs(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		name    string
+		specs   []*Spec
+		failure bool
+	}{
+		{
+			name:  "valid",
+			specs: []*Spec{{Repo: "index.docker.io/octocat/api", Tags: []string{"api"}}},
+		},
+		{
+			name: "same tag for different repos",
+			specs: []*Spec{
+				{Repo: "index.docker.io/octocat/api"},
+				{Repo: "index.docker.io/octocat/web"},
+			},
+		},
+		{
+			name:    "invalid repo",
+			specs:   []*Spec{{Repo: "index.docker.io/octocat/api:latest"}},
+			failure: true,
+		},
+		{
+			name:    "invalid tag",
+			specs:   []*Spec{{Tags: []string{"feature/api"}}},
+			failure: true,
+		},
+		{
+			name: "duplicate destination",
+			specs: []*Spec{
+				{Target: "release"},
+				{Target: "debug", Tags: []string{"debug", "latest"}},
+			},
+			failure: true,
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Plugin{
+				Build: &Build{
+					Event: "push",
+					Sha:   "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+				},
+				Image: &Image{
+					Context:    ".",
+					Dockerfile: "Dockerfile",
+					Builds:     test.specs,
+				},
+				Registry: &Registry{
+					Name:     "index.docker.io",
+					Username: "octocat",
+					Password: "superSecretPassword",
+				},
+				Repo: &Repo{
+					Name:  "index.docker.io/octocat/hello-world",
+					Tags:  []string{"latest"},
+					Label: testLabel(),
+				},
+			}
+
+			err := p.Validate()
+
+			if test.failure && err == nil {
+				t.Errorf("Validate should have returned err")
+			}
+
+			if !test.failure && err != nil {
+				t.Errorf("Validate returned err: %v", err)
+			}
+		})
+	}
+}
+
+func TestDocker_Plugin_Sanitize_Specs(t *testing.T) {
+	// setup types
+	p := &Plugin{
+		Image: &Image{
+			Builds: []*Spec{
+				{Tags: []string{"feature/API"}},
+				{Repo: "index.docker.io/octocat/web"},
+			},
+		},
+		Repo: &Repo{
+			Name:         "index.docker.io/octocat/hello-world",
+			Tags:         []string{"feature/Foo"},
+			SanitizeTags: true,
+		},
+	}
+
+	// run test
+	err := p.Sanitize()
+	if err != nil {
+		t.Errorf("Sanitize returned err: %v", err)
+	}
+
+	if !reflect.DeepEqual(p.Repo.Tags, []string{"feature-foo"}) {
+		t.Errorf("Sanitize repo tags is %v, want %v", p.Repo.Tags, []string{"feature-foo"})
+	}
+
+	if !reflect.DeepEqual(p.Image.Builds[0].Tags, []string{"feature-api"}) {
+		t.Errorf("Sanitize build tags is %v, want %v", p.Image.Builds[0].Tags, []string{"feature-api"})
+	}
+
+	if len(p.Image.Builds[1].Tags) != 0 {
+		t.Errorf("Sanitize build tags is %v, want none", p.Image.Builds[1].Tags)
+	}
+}
+
+func TestDocker_Plugin_Exec_Specs(t *testing.T) {
+	// restore the kaniko executor after the test
+	bin := kanikoBin
+
+	t.Cleanup(func() { kanikoBin = bin })
+
+	// setup tests
+	tests := []struct {
+		name            string
+		continueOnError bool
+		want            []string
+	}{
+		{
+			name: "stop on error",
+			want: []string{"version", "--dockerfile=api/Dockerfile", "--dockerfile=fail/Dockerfile"},
+		},
+		{
+			name:            "continue on error",
+			continueOnError: true,
+			want:            []string{"version", "--dockerfile=api/Dockerfile", "--dockerfile=fail/Dockerfile", "--dockerfile=web/Dockerfile"},
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// setup filesystem
+			appFS = afero.NewMemMapFs()
+
+			// setup executor which records each run and fails for the fail dockerfile
+			dir := t.TempDir()
+			out := filepath.Join(dir, "runs")
+
+			script := "#!/bin/sh\n" +
+				"for arg in \"$@\"; do\n" +
+				"  case \"$arg\" in\n" +
+				"    version|--dockerfile=*) echo \"$arg\" >> " + out + " ;;\n" +
+				"    --cleanup) cleanup=1 ;;\n" +
+				"  esac\n" +
+				"done\n" +
+				"[ \"$1\" = version ] || [ -n \"$cleanup\" ] || exit 2\n" +
+				"case \"$*\" in *--dockerfile=fail/*) exit 1 ;; esac\n"
+
+			kanikoBin = filepath.Join(dir, "executor")
+
+			err := os.WriteFile(kanikoBin, []byte(script), 0755)
+			if err != nil {
+				t.Errorf("WriteFile returned err: %v", err)
+			}
+
+			p := &Plugin{
+				Build: &Build{
+					Event: "push",
+					Sha:   "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+				},
+				Image: &Image{
+					Context:         ".",
+					Dockerfile:      "Dockerfile",
+					ContinueOnError: test.continueOnError,
+					Builds: []*Spec{
+						{Dockerfile: "api/Dockerfile", Repo: "index.docker.io/octocat/api"},
+						{Dockerfile: "fail/Dockerfile", Repo: "index.docker.io/octocat/fail"},
+						{Dockerfile: "web/Dockerfile", Repo: "index.docker.io/octocat/web"},
+					},
+				},
+				Registry: &Registry{
+					Name:     "index.docker.io",
+					Username: "octocat",
+					Password: "superSecretPassword",
+				},
+				Repo: &Repo{
+					Name:  "index.docker.io/octocat/hello-world",
+					Tags:  []string{"latest"},
+					Label: testLabel(),
+				},
+			}
+
+			err = p.Exec(t.Context())
+			if err == nil || !strings.Contains(err.Error(), "index.docker.io/octocat/fail") {
+				t.Errorf("Exec err is %v, want failure for index.docker.io/octocat/fail", err)
+			}
+
+			runs, err := os.ReadFile(out)
+			if err != nil {
+				t.Errorf("ReadFile returned err: %v", err)
+			}
+
+			got := strings.Fields(string(runs))
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Exec runs are %v, want %v", got, test.want)
+			}
+		})
+	}
+}
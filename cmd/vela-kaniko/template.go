@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// shortLength defines the length of a value shortened by the short template function.
+const shortLength = 7
+
+// templateData represents the data available to tag and label templates.
+type templateData struct {
+	// branch for the build
+	Branch string
+	// event generated for build
+	Event string
+	// SHA-1 hash generated for commit
+	Sha string
+	// tag generated for build
+	Tag string
+	// number of the pull request for the build
+	PullRequest string
+	// source branch of the pull request for the build
+	PullRequestSource string
+	// target branch of the pull request for the build
+	PullRequestTarget string
+	// target environment of the deployment for the build
+	Deployment string
+	// name of the schedule for the build
+	Schedule string
+	// build number from vela
+	Number int
+	// author from the source commit
+	Author string
+	// full name of the repository
+	Repo string
+	// direct url of the repository
+	URL string
+	// direct url of the build
+	BuildURL string
+	// host that the image is built on
+	Host string
+	// Vela environment variables describing the build and repo
+	Env map[string]string
+	// timestamp when the image was built
+	created time.Time
+}
+
+// templateEnv defines the Vela environment variables available to tag and
+// label templates, which only describe the build and repo, since other
+// variables, such as VELA_NETRC_PASSWORD, may contain secrets.
+var templateEnv = []string{
+	"VELA_BUILD_AUTHOR",
+	"VELA_BUILD_AUTHOR_EMAIL",
+	"VELA_BUILD_BRANCH",
+	"VELA_BUILD_COMMIT",
+	"VELA_BUILD_CREATED",
+	"VELA_BUILD_EVENT",
+	"VELA_BUILD_EVENT_ACTION",
+	"VELA_BUILD_LINK",
+	"VELA_BUILD_NUMBER",
+	"VELA_BUILD_REF",
+	"VELA_BUILD_SENDER",
+	"VELA_BUILD_TAG",
+	"VELA_BUILD_TITLE",
+	"VELA_DEPLOYMENT",
+	"VELA_PULL_REQUEST",
+	"VELA_PULL_REQUEST_SOURCE",
+	"VELA_PULL_REQUEST_TARGET",
+	"VELA_REPO_BRANCH",
+	"VELA_REPO_CLONE",
+	"VELA_REPO_FULL_NAME",
+	"VELA_REPO_LINK",
+	"VELA_REPO_NAME",
+	"VELA_REPO_ORG",
+	"VELA_REPO_TOPICS",
+	"VELA_REPO_VISIBILITY",
+}
+
+// templateFuncs defines the functions available to tag and label templates.
+var templateFuncs = template.FuncMap{
+	"short":   short,
+	"lower":   strings.ToLower,
+	"replace": replace,
+	"trunc":   trunc,
+}
+
+// Date formats the timestamp when the image was built with the Go time layout.
+func (d *templateData) Date(layout string) string {
+	return d.created.Format(layout)
+}
+
+// Render renders the tags and labels for the image that are written as Go templates.
+//
+// https://pkg.go.dev/text/template
+func (p *Plugin) Render() error {
+	logrus.Trace("rendering tag and label templates")
+
+	data := p.templateData()
+
+	var err error
+
+	// render the tags for the image
+	p.Repo.Tags, err = renderAll(p.Repo.Tags, data)
+	if err != nil {
+		return err
+	}
+
+	// render the tags for each build spec
+	for _, s := range p.Image.Builds {
+		s.Tags, err = renderAll(s.Tags, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	// render the labels for the image
+	p.Repo.Labels, err = renderAll(p.Repo.Labels, data)
+	if err != nil {
+		return err
+	}
+
+	// render the custom labels for the image
+	p.Repo.Label.CustomSet, err = renderAll(p.Repo.Label.CustomSet, data)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// templateData creates the data for rendering templates from the plugin configuration.
+func (p *Plugin) templateData() *templateData {
+	data := &templateData{
+		Branch:            p.Build.Branch,
+		Event:             p.Build.Event,
+		Sha:               p.Build.Sha,
+		Tag:               p.Build.Tag,
+		PullRequest:       p.Build.PullRequest,
+		PullRequestSource: p.Build.PullRequestSource,
+		PullRequestTarget: p.Build.PullRequestTarget,
+		Deployment:        p.Build.Deployment,
+		Schedule:          p.Build.Schedule,
+		Number:            p.Repo.Label.Number,
+		Author:            p.Repo.Label.AuthorEmail,
+		Repo:              p.Repo.Label.FullName,
+		URL:               p.Repo.Label.URL,
+		BuildURL:          p.Repo.Label.BuildURL,
+		Host:              p.Repo.Label.Host,
+		Env:               make(map[string]string),
+		created:           time.Now(),
+	}
+
+	// use the timestamp from the image labels, so both match
+	created, err := time.Parse(time.RFC3339, p.Repo.Label.Created)
+	if err == nil {
+		data.created = created
+	}
+
+	// only the allowed Vela variables are included, which excludes secrets
+	for _, key := range templateEnv {
+		value, ok := os.LookupEnv(key)
+		if ok {
+			data.Env[key] = value
+		}
+	}
+
+	return data
+}
+
+// renderAll renders each of the values that are written as Go templates.
+func renderAll(values []string, data *templateData) ([]string, error) {
+	rendered := []string{}
+
+	for _, value := range values {
+		// check if the value is a template
+		if !strings.Contains(value, "{{") {
+			rendered = append(rendered, value)
+
+			continue
+		}
+
+		tmpl, err := template.New(value).
+			Option("missingkey=error").
+			Funcs(templateFuncs).
+			Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse template %s: %w", value, err)
+		}
+
+		out := new(strings.Builder)
+
+		err = tmpl.Execute(out, data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to render template %s: %w", value, err)
+		}
+
+		logrus.Debugf("rendered template %s as %s", value, out)
+
+		rendered = append(rendered, out.String())
+	}
+
+	return rendered, nil
+}
+
+// short returns the first characters of the value, such as a short commit SHA.
+func short(value string) string {
+	return trunc(shortLength, value)
+}
+
+// replace replaces all occurrences of old with replacement in the value.
+func replace(old, replacement, value string) string {
+	return strings.ReplaceAll(value, old, replacement)
+}
+
+// trunc returns at most the first length characters of the value.
+func trunc(length int, value string) string {
+	runes := []rune(value)
+
+	if length < 0 || len(runes) <= length {
+		return value
+	}
+
+	return string(runes[:length])
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDocker_Plugin_Render(t *testing.T) {
+	// setup environment
+	t.Setenv("VELA_REPO_ORG", "octocat")
+
+	// setup types
+	p := &Plugin{
+		Build: &Build{
+			Branch: "feature/Hello",
+			Event:  "push",
+			Sha:    "eeea105fed7fc11bda4b43a00edfc49a5c982968",
+		},
+		Image: &Image{
+			Builds: []*Spec{
+				{Tags: []string{"{{ .Sha | short }}"}},
+			},
+		},
+		Repo: &Repo{
+			Name: "index.docker.io/octocat/hello-world",
+			Tags: []string{
+				"latest",
+				"{{ .Branch | replace \"/\" \"-\" | lower }}-{{ .Sha | short }}",
+				"{{ .Number }}",
+				"{{ .Date \"20060102\" }}",
+				"{{ .Env.VELA_REPO_ORG | trunc 3 }}",
+			},
+			Labels: []string{"io.vela.build.event={{ .Event }}"},
+			Label: &Label{
+				Number:    42,
+				Created:   "2024-02-03T04:05:06Z",
+				CustomSet: []string{"branch={{ .Branch }}"},
+			},
+		},
+	}
+
+	err := p.Render()
+	if err != nil {
+		t.Errorf("Render returned err: %v", err)
+	}
+
+	want := []string{"latest", "feature-hello-eeea105", "42", "20240203", "oct"}
+
+	if !reflect.DeepEqual(p.Repo.Tags, want) {
+		t.Errorf("Render tags is %v, want %v", p.Repo.Tags, want)
+	}
+
+	if !reflect.DeepEqual(p.Image.Builds[0].Tags, []string{"eeea105"}) {
+		t.Errorf("Render build tags is %v, want %v", p.Image.Builds[0].Tags, []string{"eeea105"})
+	}
+
+	if !reflect.DeepEqual(p.Repo.Labels, []string{"io.vela.build.event=push"}) {
+		t.Errorf("Render labels is %v, want %v", p.Repo.Labels, []string{"io.vela.build.event=push"})
+	}
+
+	if !reflect.DeepEqual(p.Repo.Label.CustomSet, []string{"branch=feature/Hello"}) {
+		t.Errorf("Render custom labels is %v, want %v", p.Repo.Label.CustomSet, []string{"branch=feature/Hello"})
+	}
+
+	// verify the rendered tags are validated
+	p.Repo.Tags = []string{"{{ .Branch }}"}
+
+	err = p.Render()
+	if err != nil {
+		t.Errorf("Render returned err: %v", err)
+	}
+
+	err = p.Repo.Validate()
+	if err == nil {
+		t.Errorf("Validate should have returned err")
+	}
+}
+
+func TestDocker_Plugin_Render_EventTags(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		build *Build
+		tag   string
+		want  string
+	}{
+		{
+			build: &Build{Event: "pull_request", PullRequest: "42", PullRequestSource: "feature", PullRequestTarget: "main"},
+			tag:   "pr-{{ .PullRequest }}-{{ .PullRequestSource }}-{{ .PullRequestTarget }}",
+			want:  "pr-42-feature-main",
+		},
+		{
+			build: &Build{Event: "deployment", Deployment: "prod"},
+			tag:   "deploy-{{ .Deployment }}",
+			want:  "deploy-prod",
+		},
+		{
+			build: &Build{Event: "schedule"},
+			tag:   "nightly-{{ .Date \"20060102\" }}",
+			want:  "nightly-20240203",
+		},
+		{
+			build: &Build{Event: "schedule", Schedule: "weekly"},
+			tag:   "{{ .Schedule }}-{{ .Date \"20060102\" }}",
+			want:  "weekly-20240203",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		p := &Plugin{
+			Build: test.build,
+			Image: &Image{},
+			Repo: &Repo{
+				Tags: []string{test.tag},
+				Label: &Label{
+					Created: "2024-02-03T04:05:06Z",
+				},
+			},
+		}
+
+		err := p.Render()
+		if err != nil {
+			t.Errorf("Render returned err: %v", err)
+		}
+
+		if !reflect.DeepEqual(p.Repo.Tags, []string{test.want}) {
+			t.Errorf("Render is %v, want %v", p.Repo.Tags, []string{test.want})
+		}
+	}
+}
+
+func TestDocker_Plugin_Render_Invalid(t *testing.T) {
+	// setup environment
+	t.Setenv("VELA_NETRC_PASSWORD", "superSecretPassword")
+
+	// setup tests
+	tests := []struct {
+		name string
+		tag  string
+	}{
+		{
+			name: "parse error",
+			tag:  "{{ .Sha",
+		},
+		{
+			name: "unknown field",
+			tag:  "{{ .Unknown }}",
+		},
+		{
+			name: "unknown environment variable",
+			tag:  "{{ .Env.VELA_UNKNOWN }}",
+		},
+		{
+			name: "secret environment variable",
+			tag:  "{{ .Env.VELA_NETRC_PASSWORD }}",
+		},
+	}
+
+	// run tests
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Plugin{
+				Build: &Build{},
+				Image: &Image{},
+				Repo: &Repo{
+					Tags:  []string{test.tag},
+					Label: &Label{},
+				},
+			}
+
+			err := p.Render()
+			if err == nil {
+				t.Errorf("Render should have returned err")
+			}
+		})
+	}
+}
+
+func TestDocker_trunc(t *testing.T) {
+	// setup tests
+	tests := []struct {
+		length int
+		value  string
+		want   string
+	}{
+		{length: 3, value: "octocat", want: "oct"},
+		{length: 3, value: "héllo", want: "hél"},
+		{length: 2, value: "日本語", want: "日本"},
+		{length: 10, value: "octocat", want: "octocat"},
+		{length: -1, value: "octocat", want: "octocat"},
+	}
+
+	// run tests
+	for _, test := range tests {
+		got := trunc(test.length, test.value)
+
+		if got != test.want {
+			t.Errorf("trunc is %s, want %s", got, test.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ go 1.25.7
 
 require (
 	github.com/Masterminds/semver/v3 v3.4.0
+	github.com/distribution/reference v0.6.0
 	github.com/go-vela/server v0.27.5
 	github.com/joho/godotenv v1.5.1
 	github.com/sirupsen/logrus v1.9.4
@@ -12,6 +13,7 @@ require (
 )
 
 require (
+	github.com/opencontainers/go-digest v1.0.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 )